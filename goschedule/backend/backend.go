@@ -1,21 +1,61 @@
 package backend
 
 import (
-	"database/sql"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
+	"time"
 
+	"github.com/kvu787/goschedule/goschedule/logging"
+	"github.com/kvu787/goschedule/goschedule/metrics"
 	"github.com/kvu787/goschedule/lib"
 )
 
-// Scrape will begin a full time schedule scrape and store results in a database.
+var (
+	pagesFetched  = metrics.NewCounter("goschedule_scrape_pages_fetched_total", "Pages fetched by the scraper.")
+	fetchErrors   = metrics.NewCounter("goschedule_scrape_fetch_errors_total", "Pages the scraper failed to fetch.")
+	fetchDuration = metrics.NewHistogram("goschedule_scrape_fetch_duration_seconds", "Time taken to fetch pages.", nil)
+)
+
+// Scrape will begin a full time schedule scrape and pass the results to store.
 // Parameter link must be a the time schedule page listing departments and colleges.
-func Scrape(link, descriptionLink string, db *sql.DB) {
-	if err := db.Ping(); err != nil {
-		panic("Bad db connection")
+// It returns a report of what was scraped. Progress is logged to logger, with
+// the department being scraped on each line about it.
+//
+// If store is a Checkpointer, departments and description pages completed by
+// an earlier, interrupted scrape into the same store are skipped. A
+// department or description page is only completed once all of its records
+// are stored, and the scrape only once all of them are. Description pages are
+// not completed while a department is not stored in full: storing the
+// department again on resume replaces its classes, and with them their
+// descriptions, which the description pages must then store again.
+func Scrape(link, descriptionLink string, store Store, logger *logging.Logger) *Report {
+	report := &Report{Link: link, Started: time.Now()}
+	logger = logger.With("link", link)
+	checkpointer, resumable := store.(Checkpointer)
+	completed := func(key string) bool {
+		if !resumable {
+			return false
+		}
+		done, err := checkpointer.Completed(key)
+		if err != nil {
+			logger.Error("reading checkpoint", "key", key, "err", err)
+			report.addError(err)
+		}
+		if done {
+			report.Resumed++
+		}
+		return done
+	}
+	complete := func(log *logging.Logger, key string) {
+		if !resumable {
+			return
+		}
+		if err := checkpointer.Complete(key); err != nil {
+			log.Error("writing checkpoint", "key", key, "err", err)
+			report.addError(err)
+		}
 	}
 	body, err := get(link)
 	if err != nil {
@@ -24,94 +64,170 @@ func Scrape(link, descriptionLink string, db *sql.DB) {
 	body = goschedule.Filter(body)
 	colleges, err := goschedule.ExtractColleges(body)
 	if err != nil {
-		log.Println(err)
+		logger.Error("extracting colleges", "err", err)
+		report.addError(err)
 	}
 	uniqueDepts := make(map[string]int)
-	fmt.Println("starting scrape")
+	uniqueInstructors := make(map[string]bool)
+	unstored := 0 // departments and description pages not stored in full
+	logger.Info("scrape started", "colleges", len(colleges))
 	// scrape colleges
 	for _, college := range colleges {
-		if err := goschedule.Insert(db, college); err != nil {
-			log.Println(err)
+		collegeLog := logger.With("college", college.Abbreviation)
+		if err := store.Insert(college); err != nil {
+			collegeLog.Error("storing college", "err", err)
+			report.addError(err)
 		}
+		report.Colleges++
 		// scrape departments for each college
 		depts, err := goschedule.ExtractDepts(body[college.Start:college.End], college.Abbreviation, link, &uniqueDepts)
 		if err != nil {
-			log.Println(err)
+			collegeLog.Error("extracting departments", "err", err)
+			report.addError(err)
 		}
 		for _, dept := range depts {
-			fmt.Printf("scraping %-70q", dept.Name)
+			deptLog := collegeLog.With("dept", dept.Name, "dept_link", dept.Link)
+			if completed(dept.Link) {
+				deptLog.Info("department skipped", "reason", "completed")
+				continue
+			}
+			start := time.Now()
 			classIndex, err := get(dept.Link)
 			if err != nil {
-				fmt.Printf("SKIPPED: %v\n       ", err)
+				deptLog.Warn("department skipped", "reason", "fetch failed", "err", err)
+				report.Skipped = append(report.Skipped, dept.Link)
 				continue
 			}
 			if err := dept.ScrapeAbbreviation(classIndex); err != nil {
-				fmt.Printf("SKIPPED: %v\n        ", err)
+				deptLog.Warn("department skipped", "reason", "no abbreviation", "err", err)
+				report.Skipped = append(report.Skipped, dept.Link)
 				continue
 			}
+			deptLog = deptLog.With("abbreviation", dept.Abbreviation)
 			classIndex = goschedule.Filter(classIndex)
-			if err := goschedule.Insert(db, dept); err != nil {
-				fmt.Print(err)
+			stored := true
+			if err := store.Insert(dept); err != nil {
+				deptLog.Error("storing department", "err", err)
+				report.addError(err)
+				stored = false
 			}
 			// scrape classes for each department
 			classes := goschedule.ExtractClasses(classIndex, dept.Abbreviation)
-			fmt.Printf("%-4d classes        ", len(classes))
+			// sections are parsed per class, so detect the column layout from the whole page
+			layout, _ := goschedule.DetectLayout(classIndex) // DefaultLayout on error
 			var sections []goschedule.Sect
 			// scrape sections for each class
 			for _, class := range classes {
-				if err := goschedule.Insert(db, class); err != nil {
-					fmt.Print(err, "      ")
+				if err := store.Insert(class); err != nil {
+					deptLog.Error("storing class", "class", class.AbbreviationCode, "err", err)
+					report.addError(err)
+					stored = false
 				}
-				sects, err := goschedule.ExtractSects(classIndex[class.Start:class.End], class.AbbreviationCode)
+				sects, err := goschedule.ExtractSectsWithLayout(classIndex[class.Start:class.End], class.AbbreviationCode, layout)
 				if err != nil {
-					fmt.Print(err, "      ")
+					deptLog.Error("extracting sections", "class", class.AbbreviationCode, "err", err)
+					report.addError(fmt.Errorf("%s: %v", class.AbbreviationCode, err))
 				}
 				sections = append(sections, sects...)
 			}
-			fmt.Printf("%-4d sections        ", len(sections))
 			for _, sect := range sections {
-				if err := goschedule.Insert(db, sect); err != nil {
-					fmt.Print(err, "      ")
+				// instructors are stored the first time they are seen
+				if instructor, ok := goschedule.ParseInstructor(sect.Instructor); ok && !uniqueInstructors[instructor.Key] {
+					uniqueInstructors[instructor.Key] = true
+					if err := store.Insert(instructor); err != nil {
+						deptLog.Error("storing instructor", "instructor", instructor.Key, "err", err)
+						report.addError(err)
+						stored = false
+					}
+					report.Instructors++
+				}
+				if err := store.Insert(sect); err != nil {
+					deptLog.Error("storing section", "sln", sect.SLN, "err", err)
+					report.addError(err)
+					stored = false
 				}
 			}
-			fmt.Print("\n")
+			// a department stored in part is scraped again on resume
+			if stored {
+				complete(deptLog, dept.Link)
+			} else {
+				unstored++
+			}
+			deptLog.Info("department scraped", "classes", len(classes), "sections", len(sections), "duration", time.Since(start))
+			report.Depts = append(report.Depts, DeptReport{dept.Abbreviation, len(classes), len(sections)})
+			report.Classes += len(classes)
+			report.Sects += len(sections)
 		}
 	}
-	fmt.Println("Scraping class descriptions...")
+	deptsStored := unstored == 0
+	logger.Info("scraping class descriptions", "index", descriptionLink)
 	descriptionBody, err := get(descriptionLink)
 	if err != nil {
 		panic(fmt.Sprintf("ERROR fetching class description index link %q: %v", descriptionLink, err))
 	}
 	descriptionLinks := goschedule.ExtractClassDescriptionLinks(descriptionBody, descriptionLink)
 	for _, link := range descriptionLinks {
+		descriptionLog := logger.With("description_link", link)
+		if completed(link) {
+			descriptionLog.Debug("descriptions skipped", "reason", "completed")
+			continue
+		}
 		descriptionBody, err := get(link)
 		if err != nil {
-			fmt.Printf("ERROR fetching class description link %q\n", link)
+			descriptionLog.Error("fetching descriptions", "err", err)
+			report.addError(err)
 			continue
 		}
 		descriptionBody = goschedule.Filter(descriptionBody)
-		descriptions, err := goschedule.ExtractClassDescriptions(descriptionBody)
+		entries, err := goschedule.ExtractCatalogEntries(descriptionBody)
 		if err != nil {
-			fmt.Printf("ERROR extracting descriptions %q: %v\n", link, err)
+			descriptionLog.Error("extracting descriptions", "err", err)
+			report.addError(fmt.Errorf("%s: %v", link, err))
 		}
-		for abbreviationCode, description := range descriptions {
-			_, err := db.Exec("UPDATE class SET description = $1 WHERE abbreviationcode = $2", description, abbreviationCode)
-			if err != nil {
-				fmt.Printf("ERROR updating db with description %q: %v\n", abbreviationCode, err)
+		var updated int
+		stored := true
+		for _, entry := range entries {
+			if err := store.UpdateDescription(entry); err != nil {
+				descriptionLog.Error("storing description", "class", entry.AbbreviationCode, "err", err)
+				report.addError(fmt.Errorf("description %s: %v", entry.AbbreviationCode, err))
+				stored = false
 				continue
 			}
+			updated++
+		}
+		report.Descriptions += updated
+		if stored && deptsStored {
+			complete(descriptionLog, link)
+		} else if !stored {
+			unstored++
 		}
-		fmt.Printf("Scraped descriptions from %q\n", link)
+		descriptionLog.Info("descriptions scraped", "descriptions", updated)
+	}
+	// a scrape with records that failed to store can be resumed to retry them
+	if unstored == 0 {
+		complete(logger, DoneKey)
+	} else {
+		logger.Warn("scrape not complete, records failed to store", "pages", unstored)
 	}
+	report.Finished = time.Now()
+	return report
 }
 
 // get requests a link with the given client and returns the string of the
 // response body if successful.
 // A response with a non-2XX/3XX status code is considered an error.
-func get(link string) (string, error) {
+func get(link string) (body string, err error) {
+	start := time.Now()
+	defer func() {
+		fetchDuration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			fetchErrors.Inc()
+		} else {
+			pagesFetched.Inc()
+		}
+	}()
 	resp, err := http.Get(link)
 	if err != nil {
-		fmt.Print(err)
 		if urlError, ok := err.(*url.Error); ok && urlError.Err.Error() == "EOF" {
 			return "", getEofError(fmt.Sprintf("get EOF error: %+v, Link: %q", err, link))
 		}
@@ -121,11 +237,11 @@ func get(link string) (string, error) {
 	if resp.StatusCode > 399 || resp.StatusCode < 200 {
 		return "", fmt.Errorf("get: returned with non-2XX/3XX status code: %d, link: %q", resp.StatusCode, link)
 	}
-	body, err := ioutil.ReadAll(resp.Body)
+	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return "", fmt.Errorf("get: error in reading response body: %v", err)
 	}
-	return string(body), nil
+	return string(data), nil
 }
 
 // getEofError is an alias for *url.Error{Op:"...", URL:"...", Err:"EOF"}.
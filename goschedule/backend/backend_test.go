@@ -0,0 +1,144 @@
+package backend
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kvu787/goschedule/goschedule/logging"
+	"github.com/kvu787/goschedule/lib"
+)
+
+// memStore is a Checkpointer that keeps the classes of a scrape, and which
+// of them have a description, in memory. Like dbStore, inserting a Dept
+// replaces the classes of the department. Sections of the departments in
+// failSects fail to store.
+type memStore struct {
+	classes     map[string]goschedule.Class
+	checkpoints map[string]bool
+	failSects   map[string]bool
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		classes:     make(map[string]goschedule.Class),
+		checkpoints: make(map[string]bool),
+		failSects:   make(map[string]bool),
+	}
+}
+
+func (s *memStore) Insert(record interface{}) error {
+	switch record := record.(type) {
+	case goschedule.Dept:
+		for key, class := range s.classes {
+			if class.DeptKey == record.Abbreviation {
+				delete(s.classes, key)
+			}
+		}
+	case goschedule.Class:
+		s.classes[record.AbbreviationCode] = record
+	case goschedule.Sect:
+		if class, ok := s.classes[record.ClassKey]; ok && s.failSects[class.DeptKey] {
+			return errors.New("connection reset")
+		}
+	}
+	return nil
+}
+
+func (s *memStore) UpdateDescription(entry goschedule.CatalogEntry) error {
+	class, ok := s.classes[entry.AbbreviationCode]
+	if !ok {
+		return nil
+	}
+	if err := class.SetCatalogEntry(entry); err != nil {
+		return err
+	}
+	s.classes[entry.AbbreviationCode] = class
+	return nil
+}
+
+func (s *memStore) Completed(key string) (bool, error) {
+	return s.checkpoints[key], nil
+}
+
+func (s *memStore) Complete(key string) error {
+	s.checkpoints[key] = true
+	return nil
+}
+
+// newScheduleServer serves the time schedule and catalog pages of the lib
+// test corpus: a time schedule index with the CSE and MATH departments at
+// /timeschd/ and a catalog index with the CSE page at /crscat/. The corpus
+// keeps the class listings of department pages only, so they are served
+// with the heading that names the department.
+func newScheduleServer(t *testing.T) *httptest.Server {
+	pages := map[string]string{
+		"/timeschd/":          "../../lib/testdata/timeschd_index.html",
+		"/timeschd/cse.html":  "../../lib/testdata/timeschd_cse.html",
+		"/timeschd/math.html": "../../lib/testdata/timeschd_math.html",
+		"/crscat/cse.html":    "../../lib/testdata/crscat_cse.html",
+	}
+	headings := map[string]string{
+		"/timeschd/cse.html":  "<h2><A NAME=cse>CSE&nbsp;&nbsp; COMPUTER SCIENCE &amp; ENGINEERING</A></h2>\n",
+		"/timeschd/math.html": "<h2><A NAME=math>MATH&nbsp;&nbsp; MATHEMATICS</A></h2>\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/crscat/" {
+			w.Write([]byte(`<a href="cse.html">Computer Science and Engineering</a>`))
+			return
+		}
+		file, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(headings[r.URL.Path]))
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestScrapeResume(t *testing.T) {
+	server := newScheduleServer(t)
+	logger := logging.New(ioutil.Discard, logging.LevelError, logging.FormatText)
+	scrape := func(store *memStore) *Report {
+		return Scrape(server.URL+"/timeschd/", server.URL+"/crscat/", store, logger)
+	}
+	described := func(store *memStore) (n int) {
+		for _, class := range store.classes {
+			if class.DeptKey == "cse" && class.Description != "" {
+				n++
+			}
+		}
+		return n
+	}
+	complete := newMemStore()
+	scrape(complete)
+	if !complete.checkpoints[DoneKey] || described(complete) == 0 {
+		t.Fatalf("full scrape: done %v, %d CSE classes described", complete.checkpoints[DoneKey], described(complete))
+	}
+	// the sections of CSE fail to store, and the scrape is resumed
+	store := newMemStore()
+	store.failSects["cse"] = true
+	if report := scrape(store); len(report.Errors) == 0 || store.checkpoints[DoneKey] {
+		t.Fatalf("failed scrape: %d errors, done %v", len(report.Errors), store.checkpoints[DoneKey])
+	}
+	delete(store.failSects, "cse")
+	report := scrape(store)
+	if !store.checkpoints[DoneKey] {
+		t.Fatalf("resumed scrape: not done, errors %q", report.Errors)
+	}
+	if report.Resumed == 0 {
+		t.Errorf("resumed scrape: nothing resumed")
+	}
+	if n, expected := described(store), described(complete); n != expected {
+		t.Errorf("resumed scrape: %d CSE classes described, expected %d", n, expected)
+	}
+}
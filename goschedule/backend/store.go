@@ -0,0 +1,278 @@
+package backend
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kvu787/goschedule/lib"
+)
+
+// A Store receives the records extracted by Scrape.
+type Store interface {
+	// Insert stores a College, Dept, Class, Sect or Instructor.
+	Insert(record interface{}) error
+	// UpdateDescription stores a parsed catalog entry with its class.
+	// Entries for classes not in the schedule are ignored.
+	UpdateDescription(entry goschedule.CatalogEntry) error
+}
+
+// A Checkpointer is a Store that records the progress of a scrape, so that
+// a scrape that stopped can be resumed in the same store. Scrape completes
+// a key for each department and description page, its link, once all of its
+// records are stored, and skips completed keys.
+type Checkpointer interface {
+	// Completed reports whether key was completed.
+	Completed(key string) (bool, error)
+	// Complete records that key was completed.
+	Complete(key string) error
+}
+
+// DoneKey is the checkpoint key completed when a scrape finishes. A store
+// with this checkpoint holds a complete scrape and cannot be resumed.
+const DoneKey = "done"
+
+// A Checkpoint records a completed part of a scrape in an application
+// database.
+type Checkpoint struct {
+	Key       string `pk:"true"`
+	Completed string // RFC 3339 time
+}
+
+// dbStore stores records in an application database.
+//
+// Storing a part of a scrape again, after an interrupted scrape, replaces
+// the records stored the first time: inserting a Dept deletes the records of
+// the department, and UpdateDescription deletes the prerequisites and joint
+// listings of the class. Colleges and instructors already stored are skipped.
+type dbStore struct {
+	db *sql.DB
+}
+
+// NewDbStore returns a Store and Checkpointer that inserts records into db,
+// which must have the tables of an application database.
+func NewDbStore(db *sql.DB) (Store, error) {
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("bad db connection: %v", err)
+	}
+	return dbStore{db}, nil
+}
+
+func (s dbStore) Insert(record interface{}) error {
+	switch record := record.(type) {
+	case goschedule.College:
+		_, err := s.db.Exec(
+			"INSERT INTO college SELECT $1, $2 WHERE NOT EXISTS (SELECT 1 FROM college WHERE abbreviation = $2)",
+			record.Name,
+			record.Abbreviation,
+		)
+		return err
+	case goschedule.Instructor:
+		_, err := s.db.Exec(
+			"INSERT INTO instructor SELECT $1, $2, $3, $4 WHERE NOT EXISTS (SELECT 1 FROM instructor WHERE key = $1)",
+			record.Key,
+			record.Name,
+			record.Last,
+			record.First,
+		)
+		return err
+	case goschedule.Dept:
+		for _, statement := range []string{
+			"DELETE FROM sect WHERE classkey IN (SELECT abbreviationcode FROM class WHERE deptkey = $1)",
+			"DELETE FROM classprereq WHERE classkey IN (SELECT abbreviationcode FROM class WHERE deptkey = $1)",
+			"DELETE FROM classjoint WHERE classkey IN (SELECT abbreviationcode FROM class WHERE deptkey = $1)",
+			"DELETE FROM class WHERE deptkey = $1",
+			"DELETE FROM dept WHERE abbreviation = $1",
+		} {
+			if _, err := s.db.Exec(statement, record.Abbreviation); err != nil {
+				return err
+			}
+		}
+	}
+	return goschedule.Insert(s.db, record)
+}
+
+func (s dbStore) Completed(key string) (bool, error) {
+	var count int
+	if err := s.db.QueryRow("SELECT count(*) FROM checkpoint WHERE key = $1", key).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s dbStore) Complete(key string) error {
+	return goschedule.Insert(s.db, Checkpoint{key, time.Now().UTC().Format(time.RFC3339)})
+}
+
+// UpdateDescription stores a parsed catalog entry in the description columns
+// of its class, and its prerequisites and joint listings in their tables.
+func (s dbStore) UpdateDescription(entry goschedule.CatalogEntry) error {
+	var class goschedule.Class
+	if err := class.SetCatalogEntry(entry); err != nil {
+		return err
+	}
+	result, err := s.db.Exec(
+		"UPDATE class SET description = $1, credits = $2, mincredits = $3, maxcredits = $4, areas = $5, prerequisites = $6, offered = $7 WHERE abbreviationcode = $8",
+		class.Description,
+		class.Credits,
+		class.MinCredits,
+		class.MaxCredits,
+		class.Areas,
+		class.Prerequisites,
+		class.Offered,
+		entry.AbbreviationCode,
+	)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil || updated < 1 {
+		return err
+	}
+	for _, statement := range []string{
+		"DELETE FROM classprereq WHERE classkey = $1",
+		"DELETE FROM classjoint WHERE classkey = $1",
+	} {
+		if _, err := s.db.Exec(statement, entry.AbbreviationCode); err != nil {
+			return err
+		}
+	}
+	for _, prereqKey := range entry.Prerequisites.Courses() {
+		if err := goschedule.Insert(s.db, goschedule.ClassPrereq{ClassKey: entry.AbbreviationCode, PrereqKey: prereqKey}); err != nil {
+			return err
+		}
+	}
+	for _, jointKey := range entry.Joint {
+		if err := goschedule.Insert(s.db, goschedule.ClassJoint{ClassKey: entry.AbbreviationCode, JointKey: jointKey}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A DirStore keeps records in memory and writes them to a directory as JSON
+// files, one per table, for scraping without a database.
+type DirStore struct {
+	dir          string
+	Colleges     []goschedule.College
+	Depts        []goschedule.Dept
+	Classes      []goschedule.Class
+	Sects        []goschedule.Sect
+	Instructors  []goschedule.Instructor
+	ClassPrereqs []goschedule.ClassPrereq
+	ClassJoints  []goschedule.ClassJoint
+	classIndices map[string]int
+}
+
+// NewDirStore returns a DirStore that writes to dir when closed.
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir, classIndices: make(map[string]int)}
+}
+
+func (s *DirStore) Insert(record interface{}) error {
+	switch record := record.(type) {
+	case goschedule.College:
+		s.Colleges = append(s.Colleges, record)
+	case goschedule.Dept:
+		s.Depts = append(s.Depts, record)
+	case goschedule.Class:
+		if _, ok := s.classIndices[record.AbbreviationCode]; ok {
+			return fmt.Errorf("duplicate class %q", record.AbbreviationCode)
+		}
+		s.classIndices[record.AbbreviationCode] = len(s.Classes)
+		s.Classes = append(s.Classes, record)
+	case goschedule.Sect:
+		s.Sects = append(s.Sects, record)
+	case goschedule.Instructor:
+		s.Instructors = append(s.Instructors, record)
+	default:
+		return fmt.Errorf("DirStore: cannot store %T", record)
+	}
+	return nil
+}
+
+func (s *DirStore) UpdateDescription(entry goschedule.CatalogEntry) error {
+	i, ok := s.classIndices[entry.AbbreviationCode]
+	if !ok {
+		return nil
+	}
+	if err := s.Classes[i].SetCatalogEntry(entry); err != nil {
+		return err
+	}
+	for _, prereqKey := range entry.Prerequisites.Courses() {
+		s.ClassPrereqs = append(s.ClassPrereqs, goschedule.ClassPrereq{ClassKey: entry.AbbreviationCode, PrereqKey: prereqKey})
+	}
+	for _, jointKey := range entry.Joint {
+		s.ClassJoints = append(s.ClassJoints, goschedule.ClassJoint{ClassKey: entry.AbbreviationCode, JointKey: jointKey})
+	}
+	return nil
+}
+
+// Write writes the records and report to the directory of s, which is
+// created if needed: colleges.json, depts.json, classes.json, sects.json,
+// instructors.json, classprereqs.json, classjoints.json and report.json.
+func (s *DirStore) Write(report *Report) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	for name, v := range map[string]interface{}{
+		"colleges":     s.Colleges,
+		"depts":        s.Depts,
+		"classes":      s.Classes,
+		"sects":        s.Sects,
+		"instructors":  s.Instructors,
+		"classprereqs": s.ClassPrereqs,
+		"classjoints":  s.ClassJoints,
+		"report":       report,
+	} {
+		if err := writeJSON(filepath.Join(s.dir, name+".json"), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJSON writes v to a file as indented JSON.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// A Report summarizes a scrape.
+type Report struct {
+	Link         string
+	Started      time.Time
+	Finished     time.Time
+	Colleges     int
+	Depts        []DeptReport
+	Classes      int
+	Sects        int
+	Instructors  int
+	Descriptions int
+	Skipped      []string // links of departments that could not be scraped
+	Resumed      int      // units of work completed by an earlier scrape
+	Errors       []string
+}
+
+// A DeptReport is the number of classes and sections scraped from a department.
+type DeptReport struct {
+	Abbreviation string
+	Classes      int
+	Sects        int
+}
+
+func (r *Report) addError(err error) {
+	r.Errors = append(r.Errors, err.Error())
+}
+
+// String summarizes r in a line.
+func (r *Report) String() string {
+	return fmt.Sprintf("%d colleges, %d departments (%d skipped), %d classes, %d sections, %d instructors, %d descriptions, %d errors in %v",
+		r.Colleges, len(r.Depts), len(r.Skipped), r.Classes, r.Sects, r.Instructors, r.Descriptions, len(r.Errors), r.Finished.Sub(r.Started))
+}
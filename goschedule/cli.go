@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/kvu787/goschedule/goschedule/logging"
+)
+
+// Exit codes of goschedule.
+const (
+	exitOK    = 0
+	exitError = 1 // the command failed
+	exitUsage = 2 // the command line is invalid
+)
+
+// A command is a subcommand of goschedule, ex. "scrape".
+type command struct {
+	name  string
+	args  string // positional arguments in the usage line, ex. "<create|teardown>"
+	short string // line in the list of commands
+	long  string // help text after the usage line and flags
+	// noConfig is set for commands that do not load the config given by
+	// --config before running.
+	noConfig bool
+	// flags defines the flags of the command on fs and returns the function
+	// running it. The function is called after the flags are parsed, with
+	// the loaded config and the positional arguments.
+	flags func(fs *flag.FlagSet) func(conf config, args []string) error
+}
+
+// globalFlags are the flags shared by every command.
+type globalFlags struct {
+	config   string
+	logLevel string
+}
+
+// globals holds the global flags of the running command.
+var globals globalFlags
+
+// add defines the global flags on fs.
+func (g *globalFlags) add(fs *flag.FlagSet) {
+	fs.StringVar(&g.config, "config", "", "JSON formatted config file at `path`.")
+	fs.StringVar(&g.logLevel, "log-level", "", "Log `level`: debug, info, warn or error. Overrides the config.")
+}
+
+// A usageError is an error in the command line.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// usagef returns a usageError formatted as by fmt.Sprintf.
+func usagef(format string, args ...interface{}) error {
+	return usageError(fmt.Sprintf(format, args...))
+}
+
+// findCommand returns the command named name.
+func findCommand(name string) (*command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// runCommand runs the command line args, without the program name, and
+// returns the exit code.
+func runCommand(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return exitUsage
+	}
+	name, args := args[0], args[1:]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		return runHelp(args)
+	}
+	c, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "goschedule: unknown command %q\n\n", name)
+		printUsage(os.Stderr)
+		return exitUsage
+	}
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	run := c.flags(fs)
+	globals.add(fs)
+	positional, err := parseFlags(fs, args)
+	if err == flag.ErrHelp {
+		c.printHelp(os.Stdout)
+		return exitOK
+	}
+	if err == nil {
+		err = configure()
+	}
+	var conf config
+	if err == nil && !c.noConfig {
+		conf, err = loadConfigFlag()
+	}
+	if err == nil {
+		err = run(conf, positional)
+	}
+	switch err.(type) {
+	case nil:
+		return exitOK
+	case usageError:
+		fmt.Fprintf(os.Stderr, "goschedule %s: %v\n\n", c.name, err)
+		c.printHelp(os.Stderr)
+		return exitUsage
+	case configErrors:
+		for _, err := range err.(configErrors) {
+			logger.Error("invalid config", "path", globals.config, "err", err)
+		}
+		return exitError
+	}
+	logger.Error(c.name+" failed", "err", err)
+	return exitError
+}
+
+// parseFlags parses the flags in args, which may come before, after or
+// between positional arguments, and returns the positional arguments.
+// Arguments after "--" are positional.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				return nil, err
+			}
+			return nil, usageError(err.Error())
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// configure sets up logging from --log-level before the config is loaded,
+// so that errors loading it are logged at the right level.
+func configure() error {
+	if globals.logLevel == "" {
+		return nil
+	}
+	level, err := logging.ParseLevel(globals.logLevel)
+	if err != nil {
+		return usagef("--log-level: %v", err)
+	}
+	logger = logging.New(os.Stderr, level, logging.FormatText)
+	logging.Default = logger
+	return nil
+}
+
+// loadConfigFlag loads the config given by --config and configures logging
+// from it and --log-level.
+func loadConfigFlag() (config, error) {
+	if globals.config == "" {
+		return config{}, usagef("missing --config flag")
+	}
+	conf, err := loadConfig(globals.config)
+	if err != nil {
+		if _, ok := err.(configErrors); ok {
+			return config{}, err
+		}
+		return config{}, fmt.Errorf("reading config %s: %v", globals.config, err)
+	}
+	if globals.logLevel != "" {
+		conf.Log.Level = globals.logLevel
+	}
+	if err := configureLogging(conf); err != nil {
+		return config{}, err
+	}
+	return conf, nil
+}
+
+// runHelp prints the help of the command named by args, or the list of
+// commands.
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return exitOK
+	}
+	c, ok := findCommand(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "goschedule help: unknown command %q\n\n", args[0])
+		printUsage(os.Stderr)
+		return exitUsage
+	}
+	c.printHelp(os.Stdout)
+	return exitOK
+}
+
+// printUsage prints the list of commands.
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, "goschedule is a tool for running the Go Schedule application.\n\n")
+	fmt.Fprint(w, "Usage:\n\n\tgoschedule <command> [flags] [arguments]\n\nCommands:\n\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "\t%-8s %s\n", c.name, c.short)
+	}
+	fmt.Fprintf(w, "\t%-8s %s\n", "help", `Use "goschedule help <command>" for more information about a command.`)
+	fmt.Fprint(w, "\nFlags may be given before or after arguments.\n\nBuilt by Kevin Vu, 2013.\n")
+}
+
+// printHelp prints the usage line, flags and help text of c.
+func (c *command) printHelp(w io.Writer) {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	c.flags(fs)
+	usageLine := "goschedule " + c.name
+	if c.args != "" {
+		usageLine += " " + c.args
+	}
+	fmt.Fprintf(w, "Usage:\n\n\t%s [flags]\n", usageLine)
+	if flags := flagDefaults(fs); flags != "" {
+		fmt.Fprintf(w, "\nFlags:\n\n%s", flags)
+	}
+	global := flag.NewFlagSet("", flag.ContinueOnError)
+	new(globalFlags).add(global)
+	fmt.Fprintf(w, "\nGlobal flags:\n\n%s", flagDefaults(global))
+	if c.long != "" {
+		fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(c.long))
+	}
+}
+
+// flagDefaults formats the flags of fs, one per line, ex.
+// "	--out=<directory>   Directory to write to. (default ".")".
+func flagDefaults(fs *flag.FlagSet) string {
+	type line struct{ name, usage string }
+	var lines []line
+	width := 0
+	fs.VisitAll(func(f *flag.Flag) {
+		placeholder, usage := flag.UnquoteUsage(f)
+		name := "--" + f.Name
+		if placeholder != "" {
+			name += "=<" + placeholder + ">"
+		}
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+			usage += fmt.Sprintf(" (default %q)", f.DefValue)
+		}
+		if len(name) > width {
+			width = len(name)
+		}
+		lines = append(lines, line{name, usage})
+	})
+	var b bytes.Buffer
+	for _, l := range lines {
+		fmt.Fprintf(&b, "\t%-*s   %s\n", width, l.name, l.usage)
+	}
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/kvu787/goschedule/goschedule/logging"
+)
+
+func TestParseFlags(t *testing.T) {
+	testSet := []struct {
+		args       []string
+		positional []string
+		schedule   string
+		dryRun     bool
+		globals    globalFlags
+		err        bool
+	}{
+		{args: nil},
+		{args: []string{"a", "b"}, positional: []string{"a", "b"}},
+		{args: []string{"a", "--schedule=aut2013", "b"}, positional: []string{"a", "b"}, schedule: "aut2013"},
+		{args: []string{"--dry-run", "a", "--schedule", "aut2013"}, positional: []string{"a"}, schedule: "aut2013", dryRun: true},
+		{args: []string{"a", "--", "--schedule=aut2013", "b"}, positional: []string{"a", "--schedule=aut2013", "b"}},
+		{args: []string{"--", "a"}, positional: []string{"a"}},
+		{args: []string{"--schedule=aut2013", "--", "--"}, positional: []string{"--"}, schedule: "aut2013"},
+		{
+			args:       []string{"create", "--config", "config.json", "--log-level=debug"},
+			positional: []string{"create"},
+			globals:    globalFlags{config: "config.json", logLevel: "debug"},
+		},
+		{args: []string{"--bogus"}, err: true},
+		{args: []string{"a", "--dry-run=maybe"}, err: true},
+		{args: []string{"a", "--schedule"}, err: true},
+	}
+	for _, test := range testSet {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		schedule := fs.String("schedule", "", "")
+		dryRun := fs.Bool("dry-run", false, "")
+		var globals globalFlags
+		globals.add(fs)
+		positional, err := parseFlags(fs, test.args)
+		if test.err {
+			if _, ok := err.(usageError); !ok {
+				t.Errorf("%q: got error %v, expected a usageError", test.args, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(positional, test.positional) || *schedule != test.schedule || *dryRun != test.dryRun || globals != test.globals {
+			t.Errorf("%q: got %q, schedule %q, dry run %v, globals %+v", test.args, positional, *schedule, *dryRun, globals)
+		}
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if _, err := parseFlags(fs, []string{"a", "-h"}); err != flag.ErrHelp {
+		t.Errorf("-h: got %v, expected flag.ErrHelp", err)
+	}
+}
+
+// discardOutput sends the standard output and error of the test, and the
+// log, to /dev/null until it ends.
+func discardOutput(t *testing.T) {
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, log := os.Stdout, os.Stderr, logger
+	os.Stdout, os.Stderr = null, null
+	t.Cleanup(func() {
+		os.Stdout, os.Stderr, logger = stdout, stderr, log
+		logging.Default = log
+		null.Close()
+	})
+}
+
+func TestRunCommandExitCodes(t *testing.T) {
+	valid := writeTestConfig(t, validConfig)
+	invalid := writeTestConfig(t, `{}`)
+	discardOutput(t)
+	testSet := []struct {
+		args []string
+		code int
+	}{
+		{nil, exitUsage},
+		{[]string{"nosuch"}, exitUsage},
+		{[]string{"help"}, exitOK},
+		{[]string{"help", "export"}, exitOK},
+		{[]string{"help", "nosuch"}, exitUsage},
+		{[]string{"export", "-h"}, exitOK},
+		{[]string{"export", "--bogus"}, exitUsage},
+		{[]string{"export", "--config", valid, "--bogus"}, exitUsage},
+		// global flags after the command and its arguments
+		{[]string{"config", "check", "--config", valid}, exitOK},
+		{[]string{"config", "check", "--config=" + valid, "--log-level", "debug"}, exitOK},
+		{[]string{"config", "--config", valid, "check", "--log-level=warn"}, exitOK},
+		{[]string{"config", "check", "--config", valid, "--log-level", "loud"}, exitUsage},
+		{[]string{"config", "check", "--config", invalid}, exitError},
+		{[]string{"config", "--config", valid}, exitUsage},
+		{[]string{"config", "--config", valid, "--", "check", "--log-level=warn"}, exitUsage},
+		{[]string{"export", "--schedule=aut2013", "--out=x"}, exitUsage},
+		{[]string{"export", "--schedule=aut2013", "--out=x", "--config", invalid}, exitError},
+		{[]string{"export", "--schedule=aut2013", "--format=xml", "--out=x", "--config", valid}, exitUsage},
+		{[]string{"export", "--schedule=win2014", "--out=x", "--config", valid}, exitUsage},
+	}
+	for _, test := range testSet {
+		globals = globalFlags{}
+		if code := runCommand(test.args); code != test.code {
+			t.Errorf("%q: got exit code %d, expected %d", test.args, code, test.code)
+		}
+	}
+	globals = globalFlags{}
+}
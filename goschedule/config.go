@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvu787/goschedule/goschedule/logging"
+	"github.com/kvu787/goschedule/goschedule/scheduler"
+	"github.com/lib/pq"
+)
+
+var configCommand = &command{
+	name:     "config",
+	args:     "check",
+	short:    "Check a config file.",
+	noConfig: true,
+	long: `
+Reads the config, applies environment variable overrides and reports every
+invalid field. Exits with status code 1 if the config is invalid.
+
+Config fields (see config.sample.json):
+
+	frontendRoot                 path of goschedule/goschedule/frontend
+	departmentDescriptionIndex   URL of the course catalog index
+	scraperTimeout               minutes between scrapes with loopScraper
+	loopScraper                  keep scraping until stopped
+	generations                  number of complete scrapes retained per
+	                             schedule, including the one served, to
+	                             roll back to; 2 if 0
+	dbLogin                      database connection:
+	    dsn                      a full connection string, "key=value ..." or
+	                             "postgres://..."; dbname is set per database
+	    host, port, user, password, dbname
+	                             used if dsn is empty; dbname is the database
+	                             used to create and drop the others
+	    sslmode                  disable, require, verify-ca or verify-full;
+	                             require if empty and dsn is not set
+	schedules                    list of schedules:
+	    name                     lowercase letters, digits and underscores,
+	                             used in database names, ex. "aut2013"
+	    url                      the time schedule page of the quarter
+	    start, end               optional first and last day of the quarter,
+	                             as YYYY-MM-DD; a looping scraper stops
+	                             scraping a schedule after its end
+	    cron                     optional list of cron expressions giving
+	                             when 'goschedule web --scheduler' scrapes
+	                             the schedule (see 'goschedule help web')
+	log                          level (debug, info, warn, error), format
+	                             (text, json) and output (stderr, stdout,
+	                             syslog or a file path)
+	admin                        user ("admin" if empty) and password of the
+	                             /admin pages of the web app, which are
+	                             disabled if the password is empty
+
+Environment variables override dbLogin fields, so secrets need not be stored
+in the config: GOSCHEDULE_DB_DSN, GOSCHEDULE_DB_HOST, GOSCHEDULE_DB_PORT,
+GOSCHEDULE_DB_USER, GOSCHEDULE_DB_PASSWORD, GOSCHEDULE_DB_NAME and
+GOSCHEDULE_DB_SSLMODE. GOSCHEDULE_ADMIN_PASSWORD overrides admin.password.`,
+	flags: func(fs *flag.FlagSet) func(config, []string) error {
+		return handleConfig
+	},
+}
+
+// config represents a JSON config file marshalled into a struct.
+type config struct {
+	FrontendRoot               string
+	DepartmentDescriptionIndex string
+	ScraperTimeout             int
+	LoopScraper                bool
+	Generations                int // complete generations retained per schedule; 2 if 0
+	DbLogin                    dbConfig
+	Schedules                  []scheduleConfig
+	Log                        struct {
+		Level  string // debug, info, warn or error; info if empty
+		Format string // text or json; text if empty
+		Output string // stderr, stdout, syslog or the path of a file; stderr if empty
+	}
+	Admin struct {
+		User     string // "admin" if empty
+		Password string // the /admin pages are disabled if empty
+	}
+}
+
+// dbConfig is the connection to the database server.
+type dbConfig struct {
+	DSN      string // overrides the fields below, except DbName
+	Host     string
+	Port     int
+	User     string
+	Password string
+	DbName   string // database used to create and drop the others
+	SSLMode  string
+}
+
+// scheduleConfig is a schedule to scrape and serve.
+type scheduleConfig struct {
+	Name  string
+	URL   string
+	Start string // first day of the quarter, "2006-01-02"; optional
+	End   string // last day of the quarter, "2006-01-02"; optional
+	// Cron holds cron expressions giving when 'goschedule web --scheduler'
+	// scrapes the schedule, ex. "*/10 * * * *" and "0 3 * * *"; optional.
+	Cron []string
+}
+
+// dateLayout is the layout of the dates of a schedule.
+const dateLayout = "2006-01-02"
+
+// sslModes are the values of dbConfig.SSLMode accepted by lib/pq.
+var sslModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+// scheduleNamePattern matches schedule names, which are used in database
+// names.
+var scheduleNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// envOverrides maps environment variables to the dbConfig fields they
+// override.
+var envOverrides = []struct {
+	name  string
+	field func(c *dbConfig) *string
+}{
+	{"GOSCHEDULE_DB_DSN", func(c *dbConfig) *string { return &c.DSN }},
+	{"GOSCHEDULE_DB_HOST", func(c *dbConfig) *string { return &c.Host }},
+	{"GOSCHEDULE_DB_USER", func(c *dbConfig) *string { return &c.User }},
+	{"GOSCHEDULE_DB_PASSWORD", func(c *dbConfig) *string { return &c.Password }},
+	{"GOSCHEDULE_DB_NAME", func(c *dbConfig) *string { return &c.DbName }},
+	{"GOSCHEDULE_DB_SSLMODE", func(c *dbConfig) *string { return &c.SSLMode }},
+}
+
+// A fieldError is a problem with a field of the config.
+type fieldError struct {
+	field   string // path of the field in the JSON config, ex. "schedules[0].url"
+	problem string
+}
+
+func (e fieldError) Error() string {
+	return e.field + ": " + e.problem
+}
+
+// configErrors are the problems found in a config.
+type configErrors []error
+
+func (e configErrors) Error() string {
+	var lines []string
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// loadConfig reads the config at path, applies environment variable
+// overrides and validates it. Errors in fields are returned as configErrors.
+func loadConfig(path string) (config, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config{}, err
+	}
+	var conf config
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&conf); err != nil {
+		return config{}, fmt.Errorf("parsing JSON: %v", err)
+	}
+	var errs configErrors
+	if port := os.Getenv("GOSCHEDULE_DB_PORT"); port != "" {
+		if conf.DbLogin.Port, err = strconv.Atoi(port); err != nil {
+			errs = append(errs, fieldError{"GOSCHEDULE_DB_PORT", fmt.Sprintf("not a port number: %q", port)})
+		}
+	}
+	for _, override := range envOverrides {
+		if value := os.Getenv(override.name); value != "" {
+			*override.field(&conf.DbLogin) = value
+		}
+	}
+	if password := os.Getenv("GOSCHEDULE_ADMIN_PASSWORD"); password != "" {
+		conf.Admin.Password = password
+	}
+	if conf.Admin.User == "" {
+		conf.Admin.User = "admin"
+	}
+	if conf.Generations == 0 {
+		conf.Generations = 2
+	}
+	if conf.DbLogin.SSLMode == "" && conf.DbLogin.DSN == "" {
+		conf.DbLogin.SSLMode = "require"
+	}
+	errs = append(errs, conf.validate()...)
+	if len(errs) > 0 {
+		return config{}, errs
+	}
+	return conf, nil
+}
+
+// validate returns the problems with the fields of c.
+func (c config) validate() configErrors {
+	var errs configErrors
+	invalid := func(field, format string, args ...interface{}) {
+		errs = append(errs, fieldError{field, fmt.Sprintf(format, args...)})
+	}
+	if err := checkURL(c.DepartmentDescriptionIndex); err != nil {
+		invalid("departmentDescriptionIndex", "%v", err)
+	}
+	if c.ScraperTimeout < 0 {
+		invalid("scraperTimeout", "must not be negative")
+	}
+	if c.LoopScraper && c.ScraperTimeout == 0 {
+		invalid("scraperTimeout", "must be set with loopScraper")
+	}
+	if c.Generations < 1 {
+		invalid("generations", "must be at least 1, got %d", c.Generations)
+	}
+	if c.DbLogin.DSN != "" {
+		if _, err := c.DbLogin.base(); err != nil {
+			invalid("dbLogin.dsn", "%v", err)
+		}
+	} else if c.DbLogin.User == "" {
+		invalid("dbLogin.user", "missing (or set dbLogin.dsn)")
+	}
+	if c.DbLogin.DbName == "" {
+		invalid("dbLogin.dbname", "missing")
+	}
+	if c.DbLogin.Port < 0 || c.DbLogin.Port > 65535 {
+		invalid("dbLogin.port", "out of range: %d", c.DbLogin.Port)
+	}
+	if c.DbLogin.SSLMode != "" && !contains(sslModes, c.DbLogin.SSLMode) {
+		invalid("dbLogin.sslmode", "must be one of %s, got %q", strings.Join(sslModes, ", "), c.DbLogin.SSLMode)
+	}
+	if len(c.Schedules) == 0 {
+		invalid("schedules", "missing")
+	}
+	names := make(map[string]bool)
+	for i, schedule := range c.Schedules {
+		field := fmt.Sprintf("schedules[%d]", i)
+		switch {
+		case !scheduleNamePattern.MatchString(schedule.Name):
+			invalid(field+".name", "must be lowercase letters, digits and underscores, got %q", schedule.Name)
+		case names[schedule.Name]:
+			invalid(field+".name", "duplicate name %q", schedule.Name)
+		}
+		names[schedule.Name] = true
+		if err := checkURL(schedule.URL); err != nil {
+			invalid(field+".url", "%v", err)
+		}
+		start, startErr := parseDate(schedule.Start)
+		if startErr != nil {
+			invalid(field+".start", "%v", startErr)
+		}
+		end, endErr := parseDate(schedule.End)
+		if endErr != nil {
+			invalid(field+".end", "%v", endErr)
+		}
+		if startErr == nil && endErr == nil && !start.IsZero() && !end.IsZero() && end.Before(start) {
+			invalid(field+".end", "before start %s", schedule.Start)
+		}
+		for j, expr := range schedule.Cron {
+			if _, err := scheduler.ParseCron(expr); err != nil {
+				invalid(fmt.Sprintf("%s.cron[%d]", field, j), "%v", err)
+			}
+		}
+	}
+	if c.Log.Level != "" {
+		if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+			invalid("log.level", "%v", err)
+		}
+	}
+	if _, err := logging.ParseFormat(c.Log.Format); err != nil {
+		invalid("log.format", "%v", err)
+	}
+	return errs
+}
+
+// checkURL returns an error if s is not an absolute http or https URL.
+func checkURL(s string) error {
+	if s == "" {
+		return fmt.Errorf("missing")
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("not an http or https URL: %q", s)
+	}
+	return nil
+}
+
+// parseDate parses a date of a schedule. The empty string is the zero time.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("not a YYYY-MM-DD date: %q", s)
+	}
+	return t, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// schedule returns the schedule of c named name.
+func (c config) schedule(name string) (scheduleConfig, bool) {
+	for _, s := range c.Schedules {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return scheduleConfig{}, false
+}
+
+// ended reports whether the quarter of s ended before t. A schedule without
+// an end date never ends.
+func (s scheduleConfig) ended(t time.Time) bool {
+	end, err := parseDate(s.End)
+	if err != nil || end.IsZero() {
+		return false
+	}
+	return !t.Before(end.AddDate(0, 0, 1))
+}
+
+// base returns the connection string of c without a database name.
+func (c dbConfig) base() (string, error) {
+	if c.DSN != "" {
+		if strings.HasPrefix(c.DSN, "postgres://") || strings.HasPrefix(c.DSN, "postgresql://") {
+			return pq.ParseURL(c.DSN)
+		}
+		return c.DSN, nil
+	}
+	var pairs []string
+	for _, pair := range [][2]string{
+		{"host", c.Host},
+		{"port", portString(c.Port)},
+		{"user", c.User},
+		{"password", c.Password},
+		{"sslmode", c.SSLMode},
+	} {
+		if pair[1] != "" {
+			pairs = append(pairs, pair[0]+"="+quoteConnValue(pair[1]))
+		}
+	}
+	return strings.Join(pairs, " "), nil
+}
+
+// connString returns the connection string of database dbname. The
+// config is validated, so the DSN is known to parse.
+func (c dbConfig) connString(dbname string) string {
+	base, _ := c.base()
+	if c.DSN != "" && c.SSLMode != "" {
+		// settings later in a connection string take precedence
+		base += " sslmode=" + quoteConnValue(c.SSLMode)
+	}
+	return strings.TrimSpace(base + " dbname=" + quoteConnValue(dbname))
+}
+
+func portString(port int) string {
+	if port == 0 {
+		return ""
+	}
+	return strconv.Itoa(port)
+}
+
+// quoteConnValue quotes a value of a key/value connection string if needed.
+func quoteConnValue(s string) string {
+	if s != "" && !strings.ContainsAny(s, ` '\`) {
+		return s
+	}
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return "'" + strings.Replace(s, `'`, `\'`, -1) + "'"
+}
+
+// configureLogging sets logger up as given by the Log section of conf,
+// which has been validated.
+func configureLogging(conf config) error {
+	level := logging.LevelInfo
+	if conf.Log.Level != "" {
+		level, _ = logging.ParseLevel(conf.Log.Level)
+	}
+	format, _ := logging.ParseFormat(conf.Log.Format)
+	out, err := logging.Open(conf.Log.Output)
+	if err != nil {
+		return fmt.Errorf("opening log output %q: %v", conf.Log.Output, err)
+	}
+	logger = logging.New(out, level, format)
+	logging.Default = logger
+	return nil
+}
+
+func handleConfig(_ config, args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return usagef("expected one argument, check")
+	}
+	if globals.config == "" {
+		return usagef("missing --config flag")
+	}
+	conf, err := loadConfig(globals.config)
+	if errs, ok := err.(configErrors); ok {
+		for _, err := range errs {
+			fmt.Printf("%s: %v\n", globals.config, err)
+		}
+		return fmt.Errorf("%s: %d invalid fields", globals.config, len(errs))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", globals.config, err)
+	}
+	fmt.Printf("%s: ok\n", globals.config)
+	for _, schedule := range conf.Schedules {
+		dates := ""
+		if schedule.Start != "" || schedule.End != "" {
+			dates = fmt.Sprintf(" (%s to %s)", schedule.Start, schedule.End)
+		}
+		fmt.Printf("schedule %s: %s%s\n", schedule.Name, schedule.URL, dates)
+	}
+	return nil
+}
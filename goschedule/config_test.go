@@ -0,0 +1,273 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// validConfig is a config that loads without errors.
+const validConfig = `{
+	"departmentDescriptionIndex": "http://www.washington.edu/students/crscat/",
+	"dbLogin": {"host": "localhost", "user": "goschedule", "dbname": "postgres"},
+	"schedules": [{"name": "aut2013", "url": "http://www.washington.edu/students/timeschd/AUT2013/"}]
+}`
+
+// envNames are the environment variables read by loadConfig.
+var envNames = []string{
+	"GOSCHEDULE_DB_DSN", "GOSCHEDULE_DB_HOST", "GOSCHEDULE_DB_PORT", "GOSCHEDULE_DB_USER",
+	"GOSCHEDULE_DB_PASSWORD", "GOSCHEDULE_DB_NAME", "GOSCHEDULE_DB_SSLMODE", "GOSCHEDULE_ADMIN_PASSWORD",
+}
+
+// writeTestConfig writes a config with the given content to a temporary
+// file, clears the environment variables read by loadConfig, and returns
+// the path of the file.
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	for _, name := range envNames {
+		t.Setenv(name, "")
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadTestConfig loads a config with the given content and no environment
+// overrides but env, pairs of names and values.
+func loadTestConfig(t *testing.T, content string, env ...string) (config, error) {
+	t.Helper()
+	path := writeTestConfig(t, content)
+	for i := 0; i+1 < len(env); i += 2 {
+		t.Setenv(env[i], env[i+1])
+	}
+	return loadConfig(path)
+}
+
+// errorFields returns the sorted fields of the fieldErrors in err.
+func errorFields(t *testing.T, err error) []string {
+	t.Helper()
+	errs, ok := err.(configErrors)
+	if !ok {
+		t.Fatalf("got %v, expected configErrors", err)
+	}
+	var fields []string
+	for _, err := range errs {
+		fieldErr, ok := err.(fieldError)
+		if !ok {
+			t.Fatalf("got %v, expected a fieldError", err)
+		}
+		fields = append(fields, fieldErr.field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestLoadConfig(t *testing.T) {
+	conf, err := loadTestConfig(t, validConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// defaults
+	if conf.Generations != 2 || conf.Admin.User != "admin" || conf.DbLogin.SSLMode != "require" {
+		t.Errorf("got generations %d, admin user %q, sslmode %q", conf.Generations, conf.Admin.User, conf.DbLogin.SSLMode)
+	}
+	if _, err := loadTestConfig(t, `{"dbLogin": {"hostname": "localhost"}}`); err == nil || !strings.Contains(err.Error(), "parsing JSON") {
+		t.Errorf("unknown field: got %v", err)
+	}
+}
+
+func TestLoadConfigMissingFields(t *testing.T) {
+	_, err := loadTestConfig(t, `{}`)
+	expected := []string{"dbLogin.dbname", "dbLogin.user", "departmentDescriptionIndex", "schedules"}
+	if fields := errorFields(t, err); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("got %v, expected %v", fields, expected)
+	}
+}
+
+func TestLoadConfigInvalidFields(t *testing.T) {
+	_, err := loadTestConfig(t, `{
+		"departmentDescriptionIndex": "ftp://www.washington.edu/",
+		"scraperTimeout": -1,
+		"generations": -1,
+		"dbLogin": {"user": "goschedule", "dbname": "postgres", "port": 70000, "sslmode": "prefer"},
+		"schedules": [
+			{"name": "Aut 2013", "url": "http://a/", "start": "2013-13-01"},
+			{"name": "win2014", "url": "not a url", "start": "2014-01-06", "end": "2014-01-05"},
+			{"name": "win2014", "url": "http://a/", "cron": ["* * * * *", "61 * * * *"]}
+		],
+		"log": {"level": "loud", "format": "xml"}
+	}`)
+	expected := []string{
+		"dbLogin.port",
+		"dbLogin.sslmode",
+		"departmentDescriptionIndex",
+		"generations",
+		"log.format",
+		"log.level",
+		"schedules[0].name",
+		"schedules[0].start",
+		"schedules[1].end",
+		"schedules[1].url",
+		"schedules[2].cron[1]",
+		"schedules[2].name",
+		"scraperTimeout",
+	}
+	if fields := errorFields(t, err); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("got %v, expected %v", fields, expected)
+	}
+	_, err = loadTestConfig(t, strings.Replace(validConfig, `"dbLogin"`, `"loopScraper": true, "dbLogin"`, 1))
+	if fields := errorFields(t, err); !reflect.DeepEqual(fields, []string{"scraperTimeout"}) {
+		t.Errorf("loopScraper without scraperTimeout: got %v", fields)
+	}
+	_, err = loadTestConfig(t, strings.Replace(validConfig, `"host": "localhost"`, `"dsn": "postgres://%zz"`, 1))
+	if fields := errorFields(t, err); !reflect.DeepEqual(fields, []string{"dbLogin.dsn"}) {
+		t.Errorf("bad dsn: got %v", fields)
+	}
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	conf, err := loadTestConfig(t, validConfig,
+		"GOSCHEDULE_DB_HOST", "db.example.com",
+		"GOSCHEDULE_DB_PORT", "6543",
+		"GOSCHEDULE_DB_USER", "scraper",
+		"GOSCHEDULE_DB_PASSWORD", "secret",
+		"GOSCHEDULE_DB_NAME", "template1",
+		"GOSCHEDULE_DB_SSLMODE", "disable",
+		"GOSCHEDULE_ADMIN_PASSWORD", "hunter2",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := dbConfig{Host: "db.example.com", Port: 6543, User: "scraper", Password: "secret", DbName: "template1", SSLMode: "disable"}
+	if conf.DbLogin != expected {
+		t.Errorf("got %+v, expected %+v", conf.DbLogin, expected)
+	}
+	if conf.Admin.Password != "hunter2" {
+		t.Errorf("got admin password %q", conf.Admin.Password)
+	}
+	// the DSN replaces the other fields, so that user need not be set
+	conf, err = loadTestConfig(t, `{
+		"departmentDescriptionIndex": "http://a/",
+		"dbLogin": {"dbname": "postgres"},
+		"schedules": [{"name": "aut2013", "url": "http://a/"}]
+	}`, "GOSCHEDULE_DB_DSN", "host=db user=scraper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.DbLogin.DSN != "host=db user=scraper" || conf.DbLogin.SSLMode != "" {
+		t.Errorf("got %+v", conf.DbLogin)
+	}
+	_, err = loadTestConfig(t, validConfig, "GOSCHEDULE_DB_PORT", "54x2")
+	if fields := errorFields(t, err); !reflect.DeepEqual(fields, []string{"GOSCHEDULE_DB_PORT"}) {
+		t.Errorf("bad port: got %v", fields)
+	}
+}
+
+func TestConnString(t *testing.T) {
+	testSet := []struct {
+		db       dbConfig
+		dbname   string
+		expected string
+	}{
+		{
+			dbConfig{Host: "localhost", Port: 5432, User: "goschedule", Password: "secret", SSLMode: "require"},
+			"aut2013_app_1",
+			"host=localhost port=5432 user=goschedule password=secret sslmode=require dbname=aut2013_app_1",
+		},
+		{
+			dbConfig{User: "goschedule"},
+			"postgres",
+			"user=goschedule dbname=postgres",
+		},
+		{
+			dbConfig{User: "goschedule", Password: `it's a \secret`},
+			"postgres",
+			`user=goschedule password='it\'s a \\secret' dbname=postgres`,
+		},
+		{
+			dbConfig{DSN: "host=db user=scraper"},
+			"postgres",
+			"host=db user=scraper dbname=postgres",
+		},
+		{
+			dbConfig{DSN: "host=db user=scraper", SSLMode: "verify-full"},
+			"postgres",
+			"host=db user=scraper sslmode=verify-full dbname=postgres",
+		},
+		{
+			dbConfig{DSN: "postgres://scraper:secret@db:6543/other", SSLMode: "disable"},
+			"postgres",
+			"dbname=other host=db password=secret port=6543 user=scraper sslmode=disable dbname=postgres",
+		},
+		{
+			dbConfig{DSN: "postgresql://scraper@db"},
+			"postgres",
+			"host=db user=scraper dbname=postgres",
+		},
+	}
+	for _, test := range testSet {
+		// the order in which lib/pq writes the settings of a URL varies by
+		// version, so only the settings are compared
+		s := test.db.connString(test.dbname)
+		if !reflect.DeepEqual(parseConnString(t, s), parseConnString(t, test.expected)) {
+			t.Errorf("%+v: got %q, expected %q", test.db, s, test.expected)
+		}
+	}
+}
+
+// parseConnString returns the settings of a key/value connection string. A
+// setting given more than once takes its last value, as in lib/pq.
+func parseConnString(t *testing.T, s string) map[string]string {
+	t.Helper()
+	settings := make(map[string]string)
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			t.Fatalf("missing = in %q", s)
+		}
+		key, value := strings.TrimSpace(s[:eq]), ""
+		s = strings.TrimLeft(s[eq+1:], " ")
+		if !strings.HasPrefix(s, "'") {
+			end := strings.IndexByte(s+" ", ' ')
+			value, s = s[:end], s[end:]
+		} else {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '\''; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			if i == len(s) {
+				t.Fatalf("unterminated quoted value in %q", s)
+			}
+			value, s = b.String(), s[i+1:]
+		}
+		settings[key] = value
+	}
+	return settings
+}
+
+func TestQuoteConnValue(t *testing.T) {
+	testSet := []struct {
+		in, expected string
+	}{
+		{"plain", "plain"},
+		{"", "''"},
+		{"two words", "'two words'"},
+		{"it's", `'it\'s'`},
+		{`back\slash`, `'back\\slash'`},
+		{`'\ `, `'\'\\ '`},
+	}
+	for _, test := range testSet {
+		if s := quoteConnValue(test.in); s != test.expected {
+			t.Errorf("quoteConnValue(%q) = %q, expected %q", test.in, s, test.expected)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kvu787/goschedule/goschedule/backend"
+	"github.com/kvu787/goschedule/goschedule/shared"
+	"github.com/kvu787/goschedule/lib"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var exportCommand = &command{
+	name:  "export",
+	short: "Write a schedule to JSON, CSV or SQLite files.",
+	long: `
+Writes the colleges, departments, classes, sections and instructors of the
+schedule being served to a file (json, sqlite) or a directory of files (csv).
+The format is described in the documentation of goschedule.TermVersion.
+
+Examples:
+
+	'goschedule export --config=./config.json --schedule=aut2013 --format=json --out=aut2013.json'
+	'goschedule export --config=./config.json --schedule=aut2013 --format=csv --out=./aut2013'`,
+	flags: func(fs *flag.FlagSet) func(config, []string) error {
+		return transferCommand(fs, "out", handleExport)
+	},
+}
+
+var importCommand = &command{
+	name:  "import",
+	short: "Load a schedule from files written by export.",
+	long: `
+Loads a file written by 'goschedule export' into the application database not
+being served for the schedule, then flips the switch to serve it, as a scrape
+would, unless the switch is pinned (see 'goschedule help switch'). Fails if
+the schedule is being scraped. Expects that 'goschedule setup create' has
+been run.`,
+	flags: func(fs *flag.FlagSet) func(config, []string) error {
+		return transferCommand(fs, "in", handleImport)
+	},
+}
+
+// transferFlags are the flags of the export and import commands.
+type transferFlags struct {
+	conf     config
+	schedule string
+	format   string
+	path     string
+}
+
+// transferCommand defines the flags of the export and import commands on fs
+// and returns the function that checks them and calls handle. pathFlag is
+// the name of the flag giving the file path ("out" or "in").
+func transferCommand(fs *flag.FlagSet, pathFlag string, handle func(transferFlags) error) func(config, []string) error {
+	schedule := fs.String("schedule", "", "Schedule `name` (from config).")
+	format := fs.String("format", "json", "File `format`: json, csv or sqlite.")
+	path := fs.String(pathFlag, "", "File or directory `path`.")
+	return func(conf config, args []string) error {
+		if len(args) > 0 {
+			return usagef("unexpected arguments %q", args)
+		}
+		if _, ok := conf.schedule(*schedule); !ok {
+			return usagef("cannot find schedule %q in config", *schedule)
+		}
+		switch *format {
+		case "json", "csv", "sqlite":
+		default:
+			return usagef("unknown format %q", *format)
+		}
+		if *path == "" {
+			return usagef("missing --%s flag", pathFlag)
+		}
+		return handle(transferFlags{conf, *schedule, *format, *path})
+	}
+}
+
+func handleExport(f transferFlags) error {
+	switchDb, err := openSwitchDb(f.conf, f.schedule)
+	if err != nil {
+		return fmt.Errorf("connecting to switch database of %s: %v", f.schedule, err)
+	}
+	defer switchDb.Close()
+	appNum, err := shared.LiveGeneration(switchDb)
+	if err != nil {
+		return fmt.Errorf("reading switch of %s: %v", f.schedule, err)
+	}
+	appDb, err := openAppDb(f.conf, f.schedule, appNum)
+	if err != nil {
+		return fmt.Errorf("connecting to application database %d: %v", appNum, err)
+	}
+	defer appDb.Close()
+	term, err := goschedule.LoadTerm(appDb, f.schedule, time.Now())
+	if err != nil {
+		return fmt.Errorf("loading schedule %s: %v", f.schedule, err)
+	}
+	switch f.format {
+	case "json":
+		file, err := os.Create(f.path)
+		if err != nil {
+			return fmt.Errorf("exporting to %s: %v", f.path, err)
+		}
+		if err := goschedule.WriteTermJSON(file, term); err != nil {
+			return fmt.Errorf("exporting to %s: %v", f.path, err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("exporting to %s: %v", f.path, err)
+		}
+	case "csv":
+		err = goschedule.WriteTermCSV(f.path, term)
+	case "sqlite":
+		if _, err := os.Stat(f.path); err == nil {
+			return fmt.Errorf("export file %s already exists", f.path)
+		}
+		var db *sql.DB
+		if db, err = sql.Open("sqlite3", f.path); err == nil {
+			err = goschedule.WriteTermSQL(db, term)
+			db.Close()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("exporting %s to %s: %v", f.format, f.path, err)
+	}
+	logger.Info("exported", "schedule", f.schedule, "classes", len(term.Classes), "sections", len(term.Sects), "path", f.path)
+	return nil
+}
+
+func handleImport(f transferFlags) error {
+	var term goschedule.Term
+	var err error
+	switch f.format {
+	case "json":
+		var file *os.File
+		if file, err = os.Open(f.path); err == nil {
+			term, err = goschedule.ReadTermJSON(file)
+			file.Close()
+		}
+	case "csv":
+		term, err = goschedule.ReadTermCSV(f.path)
+	case "sqlite":
+		if _, err = os.Stat(f.path); err == nil {
+			var db *sql.DB
+			if db, err = sql.Open("sqlite3", f.path); err == nil {
+				term, err = goschedule.ReadTermSQL(db)
+				db.Close()
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s export %s: %v", f.format, f.path, err)
+	}
+	started := time.Now()
+	switchDb, unlock, err := lockSchedule(context.Background(), f.conf, f.schedule)
+	if err != nil {
+		return fmt.Errorf("locking schedule %s: %v", f.schedule, err)
+	}
+	defer switchDb.Close()
+	defer unlock()
+	appNum, err := newGeneration(f.conf, f.schedule, switchDb)
+	if err != nil {
+		return fmt.Errorf("creating generation of %s: %v", f.schedule, err)
+	}
+	appDb, err := openAppDb(f.conf, f.schedule, appNum)
+	if err != nil {
+		return fmt.Errorf("connecting to application database %d: %v", appNum, err)
+	}
+	defer appDb.Close()
+	if err := goschedule.StoreTerm(appDb, term); err != nil {
+		return fmt.Errorf("storing schedule in application database %d: %v", appNum, err)
+	}
+	// mark the database complete, as a scrape does, so that it can be
+	// rolled back to
+	finished := time.Now()
+	if err := goschedule.Insert(appDb, backend.Checkpoint{Key: backend.DoneKey, Completed: finished.UTC().Format(time.RFC3339)}); err != nil {
+		return fmt.Errorf("storing schedule in application database %d: %v", appNum, err)
+	}
+	report := &backend.Report{Link: f.path, Started: started, Finished: finished, Classes: len(term.Classes), Sects: len(term.Sects)}
+	if err := shared.SaveReport(switchDb, appNum, report); err != nil {
+		logger.Error("saving report", "schedule", f.schedule, "err", err)
+	}
+	log := logger.With("schedule", f.schedule)
+	err = shared.FlipSwitch(switchDb, appNum, currentUser(), "import", f.path)
+	if err == shared.ErrPinned {
+		log.Warn("switch pinned, not flipping", "imported", appNum)
+	} else if err != nil {
+		return fmt.Errorf("flipping switch of %s: %v", f.schedule, err)
+	} else {
+		log.Info("imported", "classes", len(term.Classes), "sections", len(term.Sects), "path", f.path, "app", appNum)
+	}
+	if err := collectGenerations(f.conf, f.schedule, switchDb, log); err != nil {
+		return fmt.Errorf("dropping expired generations of %s: %v", f.schedule, err)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package frontend
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvu787/goschedule/goschedule/backend"
+	"github.com/kvu787/goschedule/goschedule/logging"
+	"github.com/kvu787/goschedule/goschedule/scheduler"
+	"github.com/kvu787/goschedule/goschedule/shared"
+)
+
+// Admin configures the /admin pages, which show the databases and scrapes
+// of every term and control its switch. They are served to users
+// authenticated with User and Password over HTTP basic authentication, and
+// are disabled if Password is empty.
+type Admin struct {
+	User     string
+	Password string
+	Terms    []Term
+	// Scrape starts scraping a term in the background, recording actor in
+	// the audit log of its switch. It returns shared.ErrLocked if the term
+	// is being scraped.
+	Scrape func(term, actor string) error
+	// Scheduler is the scrape scheduler shown at /admin/scheduler, or nil
+	// if there is none.
+	Scheduler *scheduler.Scheduler
+}
+
+// A Term is a schedule managed at /admin.
+type Term struct {
+	Name     string
+	SwitchDb *sql.DB
+	// AppConn returns the connection string of application database appNum.
+	AppConn func(appNum int) string
+}
+
+// openApp connects to application database appNum of t.
+func (t Term) openApp(appNum int) (*sql.DB, error) {
+	return sql.Open(appDriver, t.AppConn(appNum))
+}
+
+// adminConf configures the /admin pages; nil disables them.
+var adminConf *Admin
+
+// rowCountTables are the tables whose rows are counted at /admin.
+var rowCountTables = []string{"college", "dept", "class", "sect", "instructor"}
+
+// auditShown is the number of audit log entries of a term shown at /admin.
+const auditShown = 5
+
+// requireAdmin serves /admin pages with h only to users authenticated as
+// the admin, and responds with a 404 error page if they are disabled. Other
+// pages are served with h.
+func requireAdmin(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the router matches paths in lowercase
+		if path := strings.ToLower(r.URL.Path); path != "/admin" && !strings.HasPrefix(path, "/admin/") {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if adminConf == nil || adminConf.Password == "" {
+			errorPage(w, http.StatusNotFound)
+			return
+		}
+		user, password, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(adminConf.User))
+		passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(adminConf.Password))
+		if !ok || userOK&passwordOK != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Go Schedule admin", charset="UTF-8"`)
+			errorPage(w, http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// termStatus is the state of a term shown at /admin.
+type termStatus struct {
+	Name        string
+	State       shared.SwitchState
+	Scraping    bool
+	Counts      []rowCount // rows of the live database
+	Audit       []shared.AuditEntry
+	Next        int   // the generation served by a flip
+	NextErr     error // why the term cannot be flipped, or nil
+	Previous    int   // the generation served by a rollback
+	PreviousErr error // why the term cannot be rolled back, or nil
+	Err         error // set if the databases of the term cannot be read
+}
+
+// rowCount is the number of rows of a table.
+type rowCount struct {
+	Table string
+	Rows  int
+}
+
+// status returns the state of t.
+func (t Term) status() termStatus {
+	status := termStatus{Name: t.Name}
+	var err error
+	if status.State, err = shared.State(t.SwitchDb, t.openApp); err != nil {
+		status.Err = err
+		return status
+	}
+	status.Next, status.NextErr = status.State.Next()
+	status.Previous, status.PreviousErr = status.State.Previous()
+	if status.Scraping, err = shared.Scraping(t.SwitchDb); err != nil {
+		status.Err = err
+		return status
+	}
+	if status.Audit, err = shared.AuditLog(t.SwitchDb, auditShown); err != nil {
+		status.Err = err
+		return status
+	}
+	if status.Counts, err = t.rowCounts(status.State.Live); err != nil {
+		status.Err = err
+	}
+	return status
+}
+
+// rowCounts counts the rows of the tables of application database appNum.
+func (t Term) rowCounts(appNum int) ([]rowCount, error) {
+	db, err := t.openApp(appNum)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var counts []rowCount
+	for _, table := range rowCountTables {
+		count := rowCount{Table: table}
+		if err := db.QueryRow("SELECT count(*) FROM " + table).Scan(&count.Rows); err != nil {
+			return nil, fmt.Errorf("app db %d: %v", appNum, err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
+
+// adminHandler serves the state and generations of every term with buttons
+// to scrape it and flip, roll back, pin or unpin its switch or serve one of
+// its generations.
+func adminHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var terms []termStatus
+	for _, term := range adminConf.Terms {
+		terms = append(terms, term.status())
+	}
+	t := template.Must(template.New("").Funcs(template.FuncMap{
+		"when":     formatRunTime,
+		"duration": scrapeDuration,
+	}).ParseFiles(
+		"templates/admin.html",
+		"templates/base.html",
+	))
+	viewBag := map[string]interface{}{
+		"terms":     terms,
+		"message":   r.FormValue("message"),
+		"scheduler": adminConf.Scheduler != nil,
+	}
+	t.ExecuteTemplate(w, "base", viewBag)
+}
+
+// adminActionHandler scrapes a term, flips, rolls back, pins or unpins its
+// switch, or serves the generation given in the form, and redirects to /admin with a message saying what was done.
+// Changes to the switch are recorded in its audit log as made by the admin
+// user, with the reason given in the form.
+func adminActionHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		errorPage(w, http.StatusMethodNotAllowed)
+		return
+	}
+	// basic authentication credentials are sent with requests from other
+	// sites too
+	if !sameOrigin(r) {
+		errorPage(w, http.StatusForbidden)
+		return
+	}
+	var term *Term
+	for i := range adminConf.Terms {
+		if adminConf.Terms[i].Name == params["term"] {
+			term = &adminConf.Terms[i]
+		}
+	}
+	if term == nil {
+		errorPage(w, http.StatusNotFound)
+		return
+	}
+	actor, _, _ := r.BasicAuth()
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	log := logging.FromContext(r.Context()).With("term", term.Name, "action", params["action"], "actor", actor)
+	var message string
+	var err error
+	switch params["action"] {
+	case "scrape":
+		err = adminConf.Scrape(term.Name, actor)
+		message = fmt.Sprintf("Started scraping %s.", term.Name)
+	case "flip":
+		err = shared.Flip(r.Context(), term.SwitchDb, term.openApp, actor, reason)
+		message = fmt.Sprintf("Flipped %s to its newer scrape.", term.Name)
+	case "rollback":
+		err = shared.Rollback(r.Context(), term.SwitchDb, term.openApp, actor, reason)
+		message = fmt.Sprintf("Rolled %s back to its previous scrape.", term.Name)
+	case "serve":
+		var appNum int
+		if appNum, err = strconv.Atoi(r.FormValue("generation")); err != nil {
+			errorPage(w, http.StatusBadRequest)
+			return
+		}
+		err = shared.Serve(r.Context(), term.SwitchDb, term.openApp, appNum, actor, reason)
+		message = fmt.Sprintf("Serving app %d of %s.", appNum, term.Name)
+	case "pin":
+		err = shared.PinSwitch(term.SwitchDb, actor, reason)
+		message = fmt.Sprintf("Pinned %s: scrapes no longer flip it.", term.Name)
+	case "unpin":
+		err = shared.UnpinSwitch(term.SwitchDb, actor, reason)
+		message = fmt.Sprintf("Unpinned %s.", term.Name)
+	default:
+		errorPage(w, http.StatusNotFound)
+		return
+	}
+	switch err {
+	case nil:
+		log.Info("admin action", "reason", reason)
+	case shared.ErrLocked, shared.ErrPinned, shared.ErrNotPinned, shared.ErrNoGeneration, shared.ErrLive,
+		shared.ErrIncomplete, shared.ErrNoNewer, shared.ErrNoOlder:
+		log.Warn("admin action refused", "err", err)
+		message = fmt.Sprintf("Cannot %s %s: %v.", params["action"], term.Name, err)
+	default:
+		panic(err)
+	}
+	http.Redirect(w, r, "/admin?message="+url.QueryEscape(message), http.StatusSeeOther)
+}
+
+// sameOrigin reports whether r was sent from a page of this site, going by
+// its Origin or else Referer header.
+func sameOrigin(r *http.Request) bool {
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+	u, err := url.Parse(source)
+	return source != "" && err == nil && u.Host == r.Host
+}
+
+// schedulerHandler serves the next and last scrape of every schedule the
+// scheduler runs.
+func schedulerHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	t := template.Must(template.New("").Funcs(template.FuncMap{
+		"when": formatRunTime,
+	}).ParseFiles(
+		"templates/admin_scheduler.html",
+		"templates/base.html",
+	))
+	viewBag := map[string]interface{}{
+		"enabled": adminConf.Scheduler != nil,
+	}
+	if adminConf.Scheduler != nil {
+		viewBag["jobs"] = adminConf.Scheduler.Status()
+	}
+	t.ExecuteTemplate(w, "base", viewBag)
+}
+
+// formatRunTime formats the time of a scheduler run or scrape, which is zero
+// if there is no such run.
+func formatRunTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format("Mon Jan 2 15:04:05 MST 2006")
+}
+
+// scrapeDuration returns how long the scrape of report took.
+func scrapeDuration(report *backend.Report) time.Duration {
+	return report.Finished.Sub(report.Started).Round(time.Second)
+}
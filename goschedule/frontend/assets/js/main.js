@@ -60,7 +60,7 @@ if (typeof String.prototype.startsWith != 'function') {
 (function () {
     "use strict";
 
-    var categories = ['All', 'Classes', 'Departments', 'Colleges'];
+    var categories = ['All', 'Instructors', 'Classes', 'Departments', 'Colleges'];
     $('#category-selector').click(function () {
         categories.unshift(categories.pop());
         $('#category-selector').text(categories[0]);
@@ -91,7 +91,8 @@ if (typeof String.prototype.startsWith != 'function') {
             ['.a', 'All'],
             ['.g', 'Colleges'],
             ['.d', 'Departments'],
-            ['.c', 'Classes']
+            ['.c', 'Classes'],
+            ['.i', 'Instructors']
         ];
         console.log(search);
         $.each(mapping, function(index, value) {
@@ -0,0 +1,78 @@
+package frontend
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kvu787/goschedule/lib"
+)
+
+// changesFeedSize is the number of most recent changes in a feed.
+const changesFeedSize = 100
+
+// atomFeed is an Atom feed (RFC 4287).
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Link    atomLink `xml:"link"`
+	Updated string   `xml:"updated"`
+	Summary string   `xml:"summary"`
+}
+
+// changesHandler serves the most recent changes to the classes and sections
+// of a department as an Atom feed.
+func changesHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	changeRecords, err := goschedule.Select(switchDatabase, goschedule.Change{}, "WHERE deptkey = $1 ORDER BY time DESC, classkey, section LIMIT $2", params["dept"], changesFeedSize)
+	if err != nil {
+		panic(err)
+	}
+	root := "http://" + r.Host
+	feed := atomFeed{
+		Title:   fmt.Sprintf("Go Schedule: changes to %s", strings.ToUpper(params["dept"])),
+		ID:      root + r.URL.Path,
+		Link:    []atomLink{{Href: root + r.URL.Path, Rel: "self"}, {Href: root + "/schedule/" + params["dept"]}},
+		Updated: time.Unix(0, 0).UTC().Format(time.RFC3339),
+		Author:  atomAuthor{"Go Schedule"},
+	}
+	for i, v := range changeRecords {
+		change := v.(goschedule.Change)
+		if i == 0 {
+			feed.Updated = change.Time
+		}
+		link := fmt.Sprintf("%s/schedule/%s/%s", root, params["dept"], change.ClassKey)
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   change.Summary(),
+			ID:      fmt.Sprintf("%s#%s/%s/%s/%s", feed.ID, change.Time, change.Kind, change.ClassKey, change.SLN),
+			Link:    atomLink{Href: link},
+			Updated: change.Time,
+			Summary: change.Summary(),
+		})
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	fmt.Fprint(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		panic(err)
+	}
+}
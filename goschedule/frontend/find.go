@@ -0,0 +1,266 @@
+package frontend
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kvu787/goschedule/lib"
+)
+
+// findPerPage is the default number of sections on a page of /find results.
+const findPerPage = 50
+
+// findFlags are the flags that can be required or excluded on /find.
+var findFlags = []goschedule.Flag{
+	goschedule.FlagRestricted, goschedule.FlagAddCode, goschedule.FlagIndependentStudy, goschedule.FlagCreditNoCredit,
+	goschedule.FlagDistanceLearning, goschedule.FlagHonors, goschedule.FlagJointlyOffered, goschedule.FlagResearch,
+	goschedule.FlagServiceLearning, goschedule.FlagWriting, goschedule.FlagNewCourse, goschedule.FlagNoFinancialAid,
+}
+
+// findResult is the JSON response of /api/find.
+type findResult struct {
+	Page     int
+	Pages    int
+	Total    int
+	Sects    []findSect
+	Schedule *findSchedule `json:",omitempty"` // nil if no sections are picked
+}
+
+// findSchedule is the schedule being built on /find: the sections picked
+// with the sln query parameter, whatever the filter, and the credits and
+// fees of taking all of them.
+type findSchedule struct {
+	Sects      []findSect
+	MinCredits int64
+	MaxCredits int64
+	FeeCents   int64
+	// Variable is set if a section has "VAR" credits, which are chosen with
+	// the instructor and not counted in the range
+	Variable bool
+}
+
+// findSect is a Sect with its meeting times parsed.
+type findSect struct {
+	goschedule.Sect
+	MeetingTimes []goschedule.MeetingTime
+}
+
+// parseSectFilter reads a SectFilter from the query parameters of r:
+//
+//	days      day abbreviations, repeated or combined (ex. "M", "TTh")
+//	after     earliest start time in minutes after midnight
+//	before    latest end time in minutes after midnight
+//	open      any non-empty value selects sections with open spots
+//	credit    the credit of a section (ex. "5", "QZ")
+//	credits   a number of credits a section can be taken for
+//	max_fee   largest fee in dollars (ex. "50")
+//	no_fee    any non-empty value selects sections without a fee
+//	require   symbol of a flag a section must have (ex. "W"), repeated
+//	exclude   symbol of a flag a section must not have, repeated
+//	building  building code
+func parseSectFilter(r *http.Request) goschedule.SectFilter {
+	r.ParseForm()
+	after, _ := strconv.Atoi(r.FormValue("after"))
+	before, _ := strconv.Atoi(r.FormValue("before"))
+	credits, _ := strconv.ParseInt(r.FormValue("credits"), 10, 64)
+	maxFee, _ := goschedule.ParseFee(r.FormValue("max_fee"))
+	return goschedule.SectFilter{
+		Days:     goschedule.MeetingTime{Days: strings.Join(r.Form["days"], "")}.Weekdays(),
+		After:    after,
+		Before:   before,
+		Open:     r.FormValue("open") != "",
+		Credit:   strings.TrimSpace(r.FormValue("credit")),
+		Credits:  credits,
+		MaxFee:   maxFee,
+		NoFee:    r.FormValue("no_fee") != "",
+		Require:  parseFlags(r.Form["require"]),
+		Exclude:  parseFlags(r.Form["exclude"]),
+		Building: strings.TrimSpace(r.FormValue("building")),
+	}
+}
+
+// parseFlags returns the flags with the given symbols, ignoring unknown ones.
+func parseFlags(symbols []string) goschedule.Flags {
+	var flags goschedule.Flags
+	for _, symbol := range symbols {
+		if flag, ok := goschedule.ParseFlag(symbol); ok {
+			flags |= goschedule.Flags(flag)
+		}
+	}
+	return flags
+}
+
+// findSects returns the page of sections selected by the query parameters
+// of r (see parseSectFilter, plus "page" and "per_page"), and the schedule
+// of the sections picked by "sln", repeated.
+func findSects(r *http.Request) (findResult, error) {
+	filter := parseSectFilter(r)
+	conditions := "ORDER BY classkey, section"
+	if filter.Open {
+		conditions = "WHERE takenspots < totalspots " + conditions
+	}
+	sectRecords, err := goschedule.Select(appDb, goschedule.Sect{}, conditions)
+	if err != nil {
+		return findResult{}, err
+	}
+	var sects []goschedule.Sect
+	for _, v := range sectRecords {
+		sects = append(sects, v.(goschedule.Sect))
+	}
+	matches := goschedule.FilterSects(sects, filter)
+	page, err := strconv.Atoi(r.FormValue("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(r.FormValue("per_page"))
+	if err != nil || perPage < 1 || perPage > 200 {
+		perPage = findPerPage
+	}
+	pageSects, pages := goschedule.Paginate(matches, page, perPage)
+	result := findResult{Page: page, Pages: pages, Total: len(matches)}
+	for _, sect := range pageSects {
+		meetingTimes, err := sect.GetMeetingTimes()
+		if err != nil {
+			return findResult{}, err
+		}
+		result.Sects = append(result.Sects, findSect{sect, meetingTimes})
+	}
+	if result.Schedule, err = findScheduleOf(r.Form["sln"]); err != nil {
+		return findResult{}, err
+	}
+	return result, nil
+}
+
+// findScheduleOf returns the schedule of the sections with the given SLNs,
+// or nil if there are none.
+func findScheduleOf(slns []string) (*findSchedule, error) {
+	if len(slns) == 0 {
+		return nil, nil
+	}
+	var placeholders []string
+	var args []interface{}
+	for i, sln := range slns {
+		placeholders = append(placeholders, "$"+strconv.Itoa(i+1))
+		args = append(args, sln)
+	}
+	sectRecords, err := goschedule.Select(appDb, goschedule.Sect{}, "WHERE sln IN ("+strings.Join(placeholders, ", ")+") ORDER BY classkey, section", args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(sectRecords) == 0 {
+		return nil, nil
+	}
+	var schedule findSchedule
+	var sects []goschedule.Sect
+	for _, v := range sectRecords {
+		sect := v.(goschedule.Sect)
+		meetingTimes, err := sect.GetMeetingTimes()
+		if err != nil {
+			return nil, err
+		}
+		if credit := sect.GetCredit(); credit.Variable && credit.Max == 0 {
+			schedule.Variable = true
+		}
+		sects = append(sects, sect)
+		schedule.Sects = append(schedule.Sects, findSect{sect, meetingTimes})
+	}
+	schedule.MinCredits, schedule.MaxCredits, schedule.FeeCents = goschedule.Totals(sects)
+	return &schedule, nil
+}
+
+// pageURL returns the URL of r with the page query parameter set to page.
+func pageURL(r *http.Request, page int) string {
+	query := url.Values{}
+	for key, values := range r.Form {
+		query[key] = values
+	}
+	query.Set("page", strconv.Itoa(page))
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// scheduleURL returns the URL of r with section sln added to or removed
+// from the picked sections.
+func scheduleURL(r *http.Request, sln string, add bool) string {
+	query := url.Values{}
+	for key, values := range r.Form {
+		query[key] = values
+	}
+	var slns []string
+	for _, picked := range r.Form["sln"] {
+		if picked != sln {
+			slns = append(slns, picked)
+		}
+	}
+	if add {
+		slns = append(slns, sln)
+	}
+	query["sln"] = slns
+	return r.URL.Path + "?" + query.Encode()
+}
+
+func findHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	result, err := findSects(r)
+	if err != nil {
+		panic(err)
+	}
+	var times []int
+	for minutes := goschedule.GridStart; minutes <= goschedule.GridEnd; minutes += goschedule.GridSlot {
+		times = append(times, minutes)
+	}
+	selected := make(map[string]bool)
+	for _, key := range []string{"days", "require", "exclude", "sln"} {
+		for _, value := range r.Form[key] {
+			selected[key+":"+value] = true
+		}
+	}
+	t := template.Must(template.New("").Funcs(template.FuncMap{
+		"upper":  strings.ToUpper,
+		"lower":  strings.ToLower,
+		"deptOf": deptOf,
+		"clock":  goschedule.FormatMinutes,
+		"cents":  goschedule.FormatCents,
+		"addURL": func(sln string) string {
+			return scheduleURL(r, sln, true)
+		},
+		"removeURL": func(sln string) string {
+			return scheduleURL(r, sln, false)
+		},
+	}).ParseFiles(
+		"templates/find.html",
+		"templates/base.html",
+	))
+	viewBag := map[string]interface{}{
+		"result":   result,
+		"searched": len(r.Form) > 0,
+		"days":     []string{"M", "T", "W", "Th", "F"},
+		"flags":    findFlags,
+		"times":    times,
+		"selected": selected,
+		"after":    r.FormValue("after"),
+		"before":   r.FormValue("before"),
+		"open":     r.FormValue("open") != "",
+		"credit":   r.FormValue("credit"),
+		"credits":  r.FormValue("credits"),
+		"maxFee":   r.FormValue("max_fee"),
+		"noFee":    r.FormValue("no_fee") != "",
+		"building": r.FormValue("building"),
+		"slns":     r.Form["sln"],
+		"prevURL":  pageURL(r, result.Page-1),
+		"nextURL":  pageURL(r, result.Page+1),
+	}
+	t.ExecuteTemplate(w, "base", viewBag)
+}
+
+func findApiHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	result, err := findSects(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
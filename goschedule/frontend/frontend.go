@@ -2,11 +2,11 @@ package frontend
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"html/template"
 	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
 	"net/http/fcgi"
@@ -15,33 +15,66 @@ import (
 	"strings"
 	"time"
 
+	"github.com/kvu787/goschedule/goschedule/logging"
 	"github.com/kvu787/goschedule/goschedule/shared"
 	"github.com/kvu787/goschedule/lib"
 )
 
 var appDb *sql.DB
 var switchDatabase *sql.DB
-var conn string
+var appDbConn func(appNum int) string
+var logger = logging.Default
 
-func Serve(connString string, switchDb *sql.DB, local bool, frontendRoot string, port int) error {
-	conn = connString
+// Serve serves Go Schedule on port, locally over HTTP or through fcgi, until
+// the process receives SIGINT or SIGTERM. On a signal, the server stops
+// accepting connections and waits, up to shutdownTimeout, for requests in
+// progress to finish. appConn returns the connection string of
+// an application database, admin configures the /admin pages, which are
+// disabled if it is nil, and requests are logged to l.
+func Serve(appConn func(appNum int) string, switchDb *sql.DB, local bool, frontendRoot string, port int, admin *Admin, l *logging.Logger) error {
+	appDbConn = appConn
 	switchDatabase = switchDb
+	adminConf = admin
+	logger = l
 	if err := os.Chdir(os.ExpandEnv(frontendRoot)); err != nil {
 		return err
 	}
+	timeQueries()
 	if local {
-		http.HandleFunc("/", router)
-		if err := http.ListenAndServe(fmt.Sprintf(":%d", port), nil); err != nil {
+		server := &http.Server{
+			Addr:         fmt.Sprintf(":%d", port),
+			Handler:      handler(),
+			ReadTimeout:  readTimeout,
+			WriteTimeout: writeTimeout,
+			IdleTimeout:  idleTimeout,
+		}
+		done := shutdownOnSignal(server.Shutdown)
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
 			return err
 		}
+		<-done
 	} else {
 		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
 		if err != nil {
 			return err
 		}
-		http.HandleFunc("/", router)
-		if err := fcgi.Serve(listener, nil); err != nil {
+		requests := &inFlight{}
+		stopping := make(chan struct{})
+		done := shutdownOnSignal(func(ctx context.Context) error {
+			close(stopping)
+			err := listener.Close()
+			if waitErr := requests.wait(ctx); waitErr != nil {
+				return waitErr
+			}
 			return err
+		})
+		if err := fcgi.Serve(listener, requests.track(handler())); err != nil {
+			select {
+			case <-stopping:
+				<-done
+			default:
+				return err
+			}
 		}
 	}
 	return nil
@@ -53,23 +86,30 @@ var routing = [][]interface{}{
 	{"/schedule", deptsHandler},
 	{"/schedule/:dept", classesHandler},
 	{"/schedule/:dept/:class", sectsHandler},
+	{"/schedule/:dept/:class/prereqs", prereqsHandler},
+	{"/schedule/:dept/:class/prereqs.dot", prereqsDotHandler},
+	{"/eligible", eligibleHandler},
+	{"/instructors/:name", instructorHandler},
+	{"/buildings/:code", buildingHandler},
+	{"/rooms/:code/:room", roomHandler},
+	{"/changes/:dept", changesHandler},
+	{"/find", findHandler},
+	{"/api/find", findApiHandler},
 	{"/assets/:type/:file", assetHandler},
+	{"/admin", adminHandler},
+	{"/admin/scheduler", schedulerHandler},
+	{"/admin/:term/:action", adminActionHandler},
 }
 
 type routeHandler func(http.ResponseWriter, *http.Request, map[string]string)
 
 func router(w http.ResponseWriter, r *http.Request) {
 	// determine application db
-	appNum, err := shared.GetSwitch(switchDatabase)
+	appNum, err := shared.LiveGeneration(switchDatabase)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to query switch database for app db number in frontend.router: %v", err))
 	}
-	if appNum == 1 {
-		appNum = 2
-	} else {
-		appNum = 1
-	}
-	appDb, err = sql.Open("postgres", fmt.Sprintf(conn, appNum))
+	appDb, err = sql.Open(appDriver, appDbConn(appNum))
 	if err != nil {
 		panic(err)
 	}
@@ -80,6 +120,7 @@ func router(w http.ResponseWriter, r *http.Request) {
 	for _, tuple := range routing {
 		handler := tuple[1].(func(http.ResponseWriter, *http.Request, map[string]string))
 		if ro := route(tuple[0].(string)); ro.match(path) {
+			setRoute(w, string(ro))
 			handler(w, r, ro.parse(path))
 			matched = true
 		}
@@ -94,57 +135,70 @@ func searchHandler(w http.ResponseWriter, r *http.Request, params map[string]str
 	search := strings.TrimSpace(r.FormValue("search"))
 	if len(strings.TrimSpace(search)) == 0 {
 		t := template.Must(template.ParseFiles("assets/js/search.js"))
-		t.ExecuteTemplate(w, "searchjs", template.HTML(`<li role="presentation" class="dropdown-header"><strong>Help</strong></li><li class="disabled"><a href="#">Start typing a query like &#39archi&#39 or &#39cse1&#39...</a></li><li role="presentation" class="divider"></li><li role="presentation" class="dropdown-header"><strong>Filtering</strong></li><li class="disabled"><a href="#">Click the green button to change the filter</a></li><li class="disabled"><a href="#">Or type &#39.a&#39 (All), &#39.g&#39 (Colleges),</a></li><li class="disabled"><a href="#">&#39.d&#39 (Departments), or &#39.c&#39 (Classes)</a></li>`))
+		t.ExecuteTemplate(w, "searchjs", template.HTML(`<li role="presentation" class="dropdown-header"><strong>Help</strong></li><li class="disabled"><a href="#">Start typing a query like &#39archi&#39 or &#39cse1&#39...</a></li><li role="presentation" class="divider"></li><li role="presentation" class="dropdown-header"><strong>Filtering</strong></li><li class="disabled"><a href="#">Click the green button to change the filter</a></li><li class="disabled"><a href="#">Or type &#39.a&#39 (All), &#39.g&#39 (Colleges),</a></li><li class="disabled"><a href="#">&#39.d&#39 (Departments), &#39.c&#39 (Classes),</a></li><li class="disabled"><a href="#">or &#39.i&#39 (Instructors)</a></li>`))
 	} else {
 		category := strings.TrimSpace(r.FormValue("category"))
 		var templatePath string
 		var colleges []goschedule.College
 		var depts []goschedule.Dept
 		var classes []goschedule.Class
+		var instructors []goschedule.Instructor
 		var err error
+		log := logging.FromContext(r.Context()).With("category", category)
 		switch category {
 		case "All":
 			templatePath = "templates/search_box/all.html"
 			colleges, err = searchColleges(search, 5)
 			if err != nil {
-				log.Println(err)
+				log.Error("searching colleges", "err", err)
 			}
 			depts, err = searchDepts(search, 5)
 			if err != nil {
-				log.Println(err)
+				log.Error("searching depts", "err", err)
 			}
 			classes, err = searchClasses(search, 5)
 			if err != nil {
-				log.Println(err)
+				log.Error("searching classes", "err", err)
+			}
+			instructors, err = searchInstructors(search, 5)
+			if err != nil {
+				log.Error("searching instructors", "err", err)
 			}
 		case "Colleges":
 			templatePath = "templates/search_box/colleges.html"
 			colleges, err = searchColleges(search, 10)
 			if err != nil {
-				log.Println(err)
+				log.Error("searching colleges", "err", err)
 			}
 		case "Departments":
 			templatePath = "templates/search_box/depts.html"
 			depts, err = searchDepts(search, 10)
 			if err != nil {
-				log.Println(err)
+				log.Error("searching depts", "err", err)
 			}
 		case "Classes":
 			templatePath = "templates/search_box/classes.html"
 			classes, err = searchClasses(search, 10)
 			if err != nil {
-				log.Println(err)
+				log.Error("searching classes", "err", err)
+			}
+		case "Instructors":
+			templatePath = "templates/search_box/instructors.html"
+			instructors, err = searchInstructors(search, 10)
+			if err != nil {
+				log.Error("searching instructors", "err", err)
 			}
 		}
 		viewBag := map[string]interface{}{
-			"colleges": colleges,
-			"depts":    depts,
-			"classes":  classes,
-			"query":    search,
+			"colleges":    colleges,
+			"depts":       depts,
+			"classes":     classes,
+			"instructors": instructors,
+			"query":       search,
 		}
 		searchTemplate, err := ioutil.ReadFile(templatePath)
 		if err != nil {
-			log.Printf("%v: file path is %q\n", err, templatePath)
+			log.Error("reading search template", "path", templatePath, "err", err)
 		}
 		var htmlBuffer = &bytes.Buffer{}
 		if err := template.Must(template.New("").Funcs(template.FuncMap{
@@ -153,7 +207,7 @@ func searchHandler(w http.ResponseWriter, r *http.Request, params map[string]str
 			"boldWords": boldWords,
 			"toHTML":    toHTML,
 		}).Parse(string(searchTemplate))).Execute(htmlBuffer, viewBag); err != nil {
-			log.Println(err)
+			log.Error("executing search template", "path", templatePath, "err", err)
 		}
 		htmlStr := htmlBuffer.String()
 		htmlStr = strings.Replace(htmlStr, "\n", "", -1)
@@ -232,6 +286,19 @@ func searchClasses(search string, limit int) ([]goschedule.Class, error) {
 	return classes, nil
 }
 
+func searchInstructors(search string, limit int) ([]goschedule.Instructor, error) {
+	records, err := goschedule.Select(appDb, goschedule.Instructor{},
+		"ORDER BY word_score($1, name) DESC, letter_score($1, name) DESC LIMIT $2", search, limit)
+	if err != nil {
+		return nil, err
+	}
+	var instructors []goschedule.Instructor
+	for _, record := range records {
+		instructors = append(instructors, record.(goschedule.Instructor))
+	}
+	return instructors, nil
+}
+
 // CREDIT: http://stackoverflow.com/questions/11467731/is-it-possible-to-have-nested-templates-in-go-using-the-standard-library-googl
 func indexHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
 	t := template.Must(template.ParseFiles(
@@ -337,12 +404,195 @@ func sectsHandler(w http.ResponseWriter, r *http.Request, params map[string]stri
 	viewBag := map[string]interface{}{
 		"dept":        dept,
 		"class":       class,
-		"sects":       sects,
+		"groups":      goschedule.GroupSects(sects),
 		"classStruct": classStruct,
 	}
 	t.ExecuteTemplate(w, "base", viewBag)
 }
 
+func instructorHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	instructorRecords, err := goschedule.Select(appDb, goschedule.Instructor{}, "WHERE key = $1", params["name"])
+	if err != nil {
+		panic(err)
+	}
+	var instructor goschedule.Instructor
+	if len(instructorRecords) > 0 {
+		instructor = instructorRecords[0].(goschedule.Instructor)
+	}
+	sectRecords, err := goschedule.Select(appDb, goschedule.Sect{}, "WHERE instructorkey = $1 ORDER BY classkey, section", params["name"])
+	if err != nil {
+		panic(err)
+	}
+	var sects []goschedule.Sect
+	for _, v := range sectRecords {
+		sects = append(sects, v.(goschedule.Sect))
+	}
+	t := template.Must(template.New("").Funcs(template.FuncMap{
+		"upper":  strings.ToUpper,
+		"deptOf": deptOf,
+	}).ParseFiles(
+		"templates/instructor.html",
+		"templates/base.html",
+	))
+	viewBag := map[string]interface{}{
+		"key":        params["name"],
+		"instructor": instructor,
+		"sects":      sects,
+	}
+	t.ExecuteTemplate(w, "base", viewBag)
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern and its escape
+// character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// buildingOccupancy returns the occupancy of every room in a building.
+func buildingOccupancy(building string) ([]goschedule.RoomOccupancy, error) {
+	sectRecords, err := goschedule.Select(appDb, goschedule.Sect{}, "WHERE meetingtimes ILIKE $1", `%"Building":"`+likeEscaper.Replace(building)+`"%`)
+	if err != nil {
+		return nil, err
+	}
+	var sects []goschedule.Sect
+	for _, v := range sectRecords {
+		sects = append(sects, v.(goschedule.Sect))
+	}
+	var rooms []goschedule.RoomOccupancy
+	for _, room := range goschedule.Occupancy(sects) {
+		if strings.EqualFold(room.Building, building) {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms, nil
+}
+
+func buildingHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	rooms, err := buildingOccupancy(params["code"])
+	if err != nil {
+		panic(err)
+	}
+	t := template.Must(template.New("").Funcs(template.FuncMap{
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}).ParseFiles(
+		"templates/building.html",
+		"templates/base.html",
+	))
+	viewBag := map[string]interface{}{
+		"building": params["code"],
+		"rooms":    rooms,
+	}
+	t.ExecuteTemplate(w, "base", viewBag)
+}
+
+func roomHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	rooms, err := buildingOccupancy(params["code"])
+	if err != nil {
+		panic(err)
+	}
+	room := goschedule.RoomOccupancy{Building: strings.ToUpper(params["code"]), Room: strings.ToUpper(params["room"])}
+	for _, v := range rooms {
+		if strings.EqualFold(v.Room, params["room"]) {
+			room = v
+		}
+	}
+	t := template.Must(template.New("").Funcs(template.FuncMap{
+		"upper":  strings.ToUpper,
+		"lower":  strings.ToLower,
+		"deptOf": deptOf,
+		"clock":  goschedule.FormatMinutes,
+	}).ParseFiles(
+		"templates/room.html",
+		"templates/base.html",
+	))
+	viewBag := map[string]interface{}{
+		"room":      room,
+		"days":      goschedule.GridDays,
+		"grid":      room.Grid(),
+		"freeSlots": room.FreeSlots(),
+	}
+	t.ExecuteTemplate(w, "base", viewBag)
+}
+
+// loadPrereqGraph builds a prerequisite graph from every class in the
+// application database that has prerequisites.
+func loadPrereqGraph() (*goschedule.PrereqGraph, error) {
+	classRecords, err := goschedule.Select(appDb, goschedule.Class{}, "WHERE prerequisites <> ''")
+	if err != nil {
+		return nil, err
+	}
+	var classes []goschedule.Class
+	for _, v := range classRecords {
+		classes = append(classes, v.(goschedule.Class))
+	}
+	return goschedule.NewPrereqGraph(classes)
+}
+
+// deptOf returns the department abbreviation of a class abbreviation code,
+// ex. "cse" for "cse142".
+func deptOf(abbreviationCode string) string {
+	return strings.TrimRight(abbreviationCode, "0123456789")
+}
+
+func prereqsHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	graph, err := loadPrereqGraph()
+	if err != nil {
+		panic(err)
+	}
+	t := template.Must(template.New("").Funcs(template.FuncMap{
+		"upper":  strings.ToUpper,
+		"lower":  strings.ToLower,
+		"deptOf": deptOf,
+	}).ParseFiles(
+		"templates/prereqs.html",
+		"templates/base.html",
+	))
+	viewBag := map[string]interface{}{
+		"dept":    params["dept"],
+		"class":   params["class"],
+		"prereq":  graph.Prereq(params["class"]),
+		"chain":   graph.Chain(params["class"]),
+		"unlocks": graph.Unlocks(params["class"]),
+		"dot":     graph.DOT(params["class"]),
+	}
+	t.ExecuteTemplate(w, "base", viewBag)
+}
+
+func prereqsDotHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	graph, err := loadPrereqGraph()
+	if err != nil {
+		panic(err)
+	}
+	w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	fmt.Fprint(w, graph.DOT(params["class"]))
+}
+
+func eligibleHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	completedInput := strings.ToLower(r.FormValue("completed"))
+	var completed []string
+	for _, class := range strings.Split(completedInput, ",") {
+		if class = strings.Replace(class, " ", "", -1); class != "" {
+			completed = append(completed, class)
+		}
+	}
+	graph, err := loadPrereqGraph()
+	if err != nil {
+		panic(err)
+	}
+	t := template.Must(template.New("").Funcs(template.FuncMap{
+		"upper":  strings.ToUpper,
+		"deptOf": deptOf,
+	}).ParseFiles(
+		"templates/eligible.html",
+		"templates/base.html",
+	))
+	viewBag := map[string]interface{}{
+		"completedInput": completedInput,
+		"completed":      completed,
+		"eligible":       graph.Eligible(completed),
+	}
+	t.ExecuteTemplate(w, "base", viewBag)
+}
+
 func assetHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
 	filePath := fmt.Sprintf("assets/%s/%s", params["type"], params["file"])
 	staticFile, err := os.Open(filePath)
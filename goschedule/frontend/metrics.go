@@ -0,0 +1,102 @@
+package frontend
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kvu787/goschedule/goschedule/logging"
+	"github.com/kvu787/goschedule/goschedule/metrics"
+)
+
+var (
+	requestDuration = metrics.NewHistogram("goschedule_http_request_duration_seconds", "Time taken to serve requests, by route.", nil, "route")
+	requestsTotal   = metrics.NewCounter("goschedule_http_requests_total", "Requests served, by route and status code.", "route", "code")
+	dbQueryDuration = metrics.NewHistogram("goschedule_db_query_duration_seconds", "Time taken by queries to the application databases.", nil)
+)
+
+// appDriver is the name of the driver used to open application databases.
+var appDriver = "postgres"
+
+var registerDriver sync.Once
+
+// timeQueries makes application databases be opened with a driver that
+// times queries in dbQueryDuration.
+func timeQueries() {
+	registerDriver.Do(func() {
+		db, err := sql.Open("postgres", "")
+		if err != nil {
+			return
+		}
+		defer db.Close()
+		sql.Register("postgres-timed", metrics.TimedDriver(db.Driver(), func(seconds float64) {
+			dbQueryDuration.Observe(seconds)
+		}))
+		appDriver = "postgres-timed"
+	})
+}
+
+// statusWriter records the status code of a response and the route that
+// served it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	route  string
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// setRoute records the route pattern serving a request, if w records it.
+func setRoute(w http.ResponseWriter, route string) {
+	if sw, ok := w.(*statusWriter); ok && sw.route == "" {
+		sw.route = route
+	}
+}
+
+// instrument records the duration and status code of each request served by
+// h, and logs it. Requests are labeled with the route set by setRoute, else the path
+// for fixed paths such as /healthz, else "unmatched".
+func instrument(h http.Handler, fixedPaths ...string) http.Handler {
+	fixed := make(map[string]bool)
+	for _, path := range fixedPaths {
+		fixed[path] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			route := sw.route
+			switch {
+			case route != "":
+			case fixed[r.URL.Path]:
+				route = r.URL.Path
+			default:
+				route = "unmatched"
+			}
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+			duration := time.Since(start)
+			requestDuration.Observe(duration.Seconds(), route)
+			requestsTotal.Inc(route, strconv.Itoa(sw.status))
+			logging.FromContext(r.Context()).Info("request served",
+				"method", r.Method, "path", r.URL.Path, "route", route,
+				"status", sw.status, "duration", duration)
+		}()
+		h.ServeHTTP(sw, r)
+	})
+}
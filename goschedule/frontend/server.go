@@ -0,0 +1,203 @@
+package frontend
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kvu787/goschedule/goschedule/logging"
+	"github.com/kvu787/goschedule/goschedule/metrics"
+	"github.com/kvu787/goschedule/goschedule/shared"
+)
+
+// Timeouts of the web server.
+const (
+	readTimeout     = 10 * time.Second
+	writeTimeout    = 30 * time.Second
+	idleTimeout     = 2 * time.Minute
+	shutdownTimeout = 15 * time.Second
+	healthTimeout   = 2 * time.Second
+)
+
+// handler returns the handler of every request: health checks, metrics, and
+// the router with panic recovery, behind authentication for /admin pages.
+func handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler)
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/", recoverer(requireAdmin(http.HandlerFunc(router))))
+	return withRequestID(instrument(mux, "/healthz", "/readyz", "/metrics"))
+}
+
+// requestIDHeader is the header holding the ID of a request, set by a proxy
+// or else generated.
+const requestIDHeader = "X-Request-Id"
+
+// withRequestID gives each request an ID, echoed in the response, and
+// a logger that logs it, available through logging.FromContext.
+func withRequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 64 {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		l := logger.With("request_id", id)
+		h.ServeHTTP(w, r.WithContext(logging.NewContext(r.Context(), l)))
+	})
+}
+
+// newRequestID returns a random 16 digit hex string.
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// recoverer recovers from panics in h, such as those of handlers on database
+// errors, logs them and responds with a 500 error page.
+func recoverer(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				logging.FromContext(r.Context()).Error("panic serving request", "path", r.URL.Path, "err", err, "stack", string(debug.Stack()))
+				errorPage(w, http.StatusInternalServerError)
+			}
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// errorPage responds with an error page for status.
+func errorPage(w http.ResponseWriter, status int) {
+	t, err := template.ParseFiles(
+		"templates/error.html",
+		"templates/base.html",
+	)
+	if err != nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	t.ExecuteTemplate(w, "base", map[string]interface{}{
+		"status":     status,
+		"statusText": http.StatusText(status),
+	})
+}
+
+// healthzHandler reports that the server is running.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyzHandler reports whether the server can serve pages: the switch
+// database can be queried and the application database it selects is up.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if err := ready(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// ready checks the switch database and the application database being
+// served.
+func ready(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	if err := switchDatabase.PingContext(ctx); err != nil {
+		return fmt.Errorf("switch db: %v", err)
+	}
+	appNum, err := shared.LiveGeneration(switchDatabase)
+	if err != nil {
+		return fmt.Errorf("switch db: %v", err)
+	}
+	db, err := sql.Open(appDriver, appDbConn(appNum))
+	if err != nil {
+		return fmt.Errorf("app db %d: %v", appNum, err)
+	}
+	defer db.Close()
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("app db %d: %v", appNum, err)
+	}
+	return nil
+}
+
+// inFlight tracks the requests being handled, so that an fcgi server, which
+// has no Shutdown method, can wait for them to finish.
+type inFlight struct {
+	mu       sync.Mutex
+	stopping bool
+	requests sync.WaitGroup
+}
+
+// track returns a handler that counts the requests handled by h. Once wait
+// is called, new requests are refused with a 503 error.
+func (f *inFlight) track(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		if f.stopping {
+			f.mu.Unlock()
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		f.requests.Add(1)
+		f.mu.Unlock()
+		defer f.requests.Done()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// wait waits for the requests being handled to finish, or for ctx to be
+// done.
+func (f *inFlight) wait(ctx context.Context) error {
+	f.mu.Lock()
+	f.stopping = true
+	f.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		f.requests.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shutdownOnSignal calls shutdown when the process receives SIGINT or
+// SIGTERM. The returned channel is closed once shutdown returns.
+func shutdownOnSignal(shutdown func(ctx context.Context) error) <-chan struct{} {
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		logger.Info("shutting down", "signal", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logger.Error("shutting down", "err", err)
+		}
+		close(done)
+	}()
+	return done
+}
@@ -0,0 +1,53 @@
+package frontend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInFlight(t *testing.T) {
+	var requests inFlight
+	started, release := make(chan struct{}), make(chan struct{})
+	h := requests.track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	finished := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		finished <- rec.Code
+	}()
+	<-started
+	// the request in progress outlasts the timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := requests.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("wait: got %v, expected context.DeadlineExceeded", err)
+	}
+	// requests after wait is called are refused
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("request while stopping: got %d, expected 503", rec.Code)
+	}
+	waited := make(chan error)
+	go func() {
+		waited <- requests.wait(context.Background())
+	}()
+	select {
+	case err := <-waited:
+		t.Fatalf("wait returned %v before the request finished", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	if code := <-finished; code != http.StatusOK {
+		t.Errorf("request in progress: got %d", code)
+	}
+	if err := <-waited; err != nil {
+		t.Errorf("wait: %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+// Package logging writes leveled, structured log lines: a message and
+// key/value pairs, formatted as text or JSON.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// A Level is the severity of a log line.
+type Level int
+
+// Levels, from least to most severe.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses a level name, ex. "info" or "WARN".
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	if strings.EqualFold(s, "warning") {
+		return LevelWarn, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// A Format is the way log lines are written.
+type Format int
+
+// Formats of log lines.
+const (
+	// FormatText writes lines of key=value pairs, ex.
+	// `time=2013-09-01T12:00:00.000Z level=info msg="scrape started" link=...`.
+	FormatText Format = iota
+	// FormatJSON writes a JSON object per line.
+	FormatJSON
+)
+
+// ParseFormat parses a format name: "text" or "json".
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "text", "":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return 0, fmt.Errorf("unknown log format %q", s)
+}
+
+// A Logger writes log lines at or above its level. Every line has the
+// key/value pairs of the Logger, followed by those of the call. Keys are
+// strings; a value without a key is logged under "!badkey".
+//
+// A Logger is safe for concurrent use; loggers made by With share their
+// output.
+type Logger struct {
+	out     *output
+	level   Level
+	format  Format
+	keyvals []interface{}
+}
+
+// output serializes writes to w.
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// New creates a Logger writing lines at or above level to w. If w is a
+// syslog writer opened by Open, each line is sent with the priority of its
+// level.
+func New(w io.Writer, level Level, format Format) *Logger {
+	return &Logger{out: &output{w: w}, level: level, format: format}
+}
+
+// Default is the Logger used by FromContext for contexts without one.
+var Default = New(os.Stderr, LevelInfo, FormatText)
+
+// With returns a Logger that adds keyvals to every line of l.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	child := *l
+	child.keyvals = append(append([]interface{}(nil), l.keyvals...), keyvals...)
+	return &child
+}
+
+// Enabled reports whether l writes lines at level.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// Debug logs msg at LevelDebug.
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.log(LevelDebug, msg, keyvals)
+}
+
+// Info logs msg at LevelInfo.
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.log(LevelInfo, msg, keyvals)
+}
+
+// Warn logs msg at LevelWarn.
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.log(LevelWarn, msg, keyvals)
+}
+
+// Error logs msg at LevelError.
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.log(LevelError, msg, keyvals)
+}
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	pairs := append([]interface{}{
+		"time", time.Now().UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		"level", level.String(),
+		"msg", msg,
+	}, l.keyvals...)
+	pairs = append(pairs, keyvals...)
+	var b bytes.Buffer
+	if l.format == FormatJSON {
+		writeJSON(&b, pairs)
+	} else {
+		writeText(&b, pairs)
+	}
+	b.WriteByte('\n')
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	if lw, ok := l.out.w.(levelWriter); ok {
+		lw.writeLevel(level, b.Bytes())
+		return
+	}
+	l.out.w.Write(b.Bytes())
+}
+
+// levelWriter is implemented by outputs, such as syslog, that record the
+// level of each line.
+type levelWriter interface {
+	writeLevel(level Level, line []byte) error
+}
+
+// pair returns the key/value pair of keyvals at i, and the index of the
+// next pair. An element at i that is not a string key, or a key without a
+// value, is the value of a "!badkey" pair of its own.
+func pair(keyvals []interface{}, i int) (string, interface{}, int) {
+	key, ok := keyvals[i].(string)
+	if !ok || i+1 == len(keyvals) {
+		return "!badkey", keyvals[i], i + 1
+	}
+	return key, keyvals[i+1], i + 2
+}
+
+// formatValue returns the text of a value: errors and Stringers by their
+// methods, others as by fmt.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "<nil>"
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+func writeText(b *bytes.Buffer, keyvals []interface{}) {
+	for i := 0; i < len(keyvals); {
+		key, value, next := pair(keyvals, i)
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		i = next
+		b.WriteString(key)
+		b.WriteByte('=')
+		s := formatValue(value)
+		if needsQuoting(s) {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(s)
+	}
+}
+
+// needsQuoting reports whether a text value must be quoted to be read back.
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(b *bytes.Buffer, keyvals []interface{}) {
+	b.WriteByte('{')
+	for i := 0; i < len(keyvals); {
+		key, value, next := pair(keyvals, i)
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		i = next
+		k, _ := json.Marshal(key)
+		b.Write(k)
+		b.WriteByte(':')
+		switch value.(type) {
+		case bool, int, int64, float64, nil:
+		default:
+			value = formatValue(value)
+		}
+		v, err := json.Marshal(value)
+		if err != nil {
+			v, _ = json.Marshal(formatValue(value))
+		}
+		b.Write(v)
+	}
+	b.WriteByte('}')
+}
+
+// Open opens a log output: "stderr", "stdout", "syslog" (the local syslog
+// daemon, under the tag "goschedule"), or else the path of a file to append
+// to.
+func Open(name string) (io.WriteCloser, error) {
+	switch name {
+	case "", "stderr":
+		return nopCloser{os.Stderr}, nil
+	case "stdout":
+		return nopCloser{os.Stdout}, nil
+	case "syslog":
+		return openSyslog("goschedule")
+	}
+	return os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries l.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the Logger carried by ctx, or Default.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return Default
+}
@@ -0,0 +1,148 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// lines returns the lines written to b, without their time.
+func lines(t *testing.T, b *bytes.Buffer) []string {
+	t.Helper()
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "time=") {
+			t.Fatalf("line without time: %q", line)
+		}
+		lines = append(lines, line[strings.IndexByte(line, ' ')+1:])
+	}
+	return lines
+}
+
+func TestText(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b, LevelDebug, FormatText)
+	l.Info("scrape started", "link", "http://a/", "colleges", 5)
+	l.Error("storing class", "err", errors.New("connection reset"), "class", "cse142")
+	l.Debug("quoted", "empty", "", "equals", "a=b", "quote", `say "hi"`, "nil", nil)
+	expected := []string{
+		`level=info msg="scrape started" link=http://a/ colleges=5`,
+		`level=error msg="storing class" err="connection reset" class=cse142`,
+		`level=debug msg=quoted empty="" equals="a=b" quote="say \"hi\"" nil=<nil>`,
+	}
+	got := lines(t, &b)
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b, LevelInfo, FormatJSON)
+	l.Warn("department skipped", "dept", "cse", "classes", 12, "resumed", true, "err", errors.New("no abbreviation"))
+	var line map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &line); err != nil {
+		t.Fatalf("%q: %v", b.String(), err)
+	}
+	if _, ok := line["time"].(string); !ok {
+		t.Errorf("missing time: %v", line)
+	}
+	delete(line, "time")
+	expected := map[string]interface{}{
+		"level":   "warn",
+		"msg":     "department skipped",
+		"dept":    "cse",
+		"classes": 12.0,
+		"resumed": true,
+		"err":     "no abbreviation",
+	}
+	if len(line) != len(expected) {
+		t.Errorf("got %v, expected %v", line, expected)
+	}
+	for key, value := range expected {
+		if line[key] != value {
+			t.Errorf("%s: got %v, expected %v", key, line[key], value)
+		}
+	}
+}
+
+func TestLevel(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b, LevelWarn, FormatText)
+	l.Debug("debug")
+	l.Info("info")
+	l.Warn("warn")
+	l.Error("error")
+	expected := []string{"level=warn msg=warn", "level=error msg=error"}
+	if got := lines(t, &b); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	if l.Enabled(LevelInfo) || !l.Enabled(LevelError) {
+		t.Errorf("Enabled: got info %v, error %v", l.Enabled(LevelInfo), l.Enabled(LevelError))
+	}
+	testSet := []struct {
+		name  string
+		level Level
+		err   bool
+	}{
+		{"debug", LevelDebug, false},
+		{"INFO", LevelInfo, false},
+		{"Warning", LevelWarn, false},
+		{"error", LevelError, false},
+		{"loud", 0, true},
+	}
+	for _, test := range testSet {
+		level, err := ParseLevel(test.name)
+		if level != test.level || (err != nil) != test.err {
+			t.Errorf("ParseLevel(%q) = %v, %v", test.name, level, err)
+		}
+	}
+}
+
+func TestWith(t *testing.T) {
+	var b bytes.Buffer
+	parent := New(&b, LevelInfo, FormatText).With("link", "http://a/")
+	child := parent.With("dept", "cse")
+	other := parent.With("dept", "math")
+	child.Info("scraped", "classes", 3)
+	other.Info("scraped")
+	parent.Info("done")
+	expected := []string{
+		"level=info msg=scraped link=http://a/ dept=cse classes=3",
+		"level=info msg=scraped link=http://a/ dept=math",
+		"level=info msg=done link=http://a/",
+	}
+	if got := lines(t, &b); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestBadKey(t *testing.T) {
+	var b bytes.Buffer
+	New(&b, LevelInfo, FormatText).Info("m", 42, "key", "value", "dangling")
+	expected := []string{"level=info msg=m !badkey=42 key=value !badkey=dangling"}
+	if got := lines(t, &b); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	b.Reset()
+	New(&b, LevelInfo, FormatJSON).Info("m", 42, "key", "value")
+	if s := b.String(); !strings.HasSuffix(s, `"msg":"m","!badkey":42,"key":"value"}`+"\n") {
+		t.Errorf("got %q", s)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if l := FromContext(context.Background()); l != Default {
+		t.Errorf("got %v, expected Default", l)
+	}
+	l := New(&bytes.Buffer{}, LevelInfo, FormatText)
+	if got := FromContext(NewContext(context.Background(), l)); got != l {
+		t.Errorf("got %v, expected the logger of the context", got)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package logging
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// syslogWriter sends lines to syslog with the priority of their level.
+type syslogWriter struct {
+	*syslog.Writer
+}
+
+func openSyslog(tag string) (io.WriteCloser, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return syslogWriter{w}, nil
+}
+
+func (w syslogWriter) writeLevel(level Level, line []byte) error {
+	switch level {
+	case LevelDebug:
+		return w.Debug(string(line))
+	case LevelInfo:
+		return w.Info(string(line))
+	case LevelWarn:
+		return w.Warning(string(line))
+	}
+	return w.Err(string(line))
+}
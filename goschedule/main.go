@@ -1,401 +1,730 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/kvu787/goschedule/goschedule/backend"
 	"github.com/kvu787/goschedule/goschedule/frontend"
+	"github.com/kvu787/goschedule/goschedule/logging"
+	"github.com/kvu787/goschedule/goschedule/scheduler"
 	"github.com/kvu787/goschedule/goschedule/shared"
 	"github.com/kvu787/goschedule/lib"
 	_ "github.com/lib/pq"
 )
 
-var usage string = `goschedule is a tool for running the Go Schedule application.
-
-Usage:
-
-    goschedule <command> [flags]
-
-Commands:
-
-    setup    Setup the databases used by scrape to store records.
-    scrape   Scrape the UW time schedule.
-    web      Start the web application to view Go Schedule.
-    help     Use "goschedule help [command] for more information about a command.
-
-Built by Kevin Vu, 2013.`
-
-var setupHelp string = `Usage:
-
-	goschedule setup <create|teardown> --config=<path to config>
+// commands are the commands of goschedule, in the order they are listed.
+var commands = []*command{
+	setupCommand,
+	scrapeCommand,
+	diffCommand,
+	switchCommand,
+	configCommand,
+	exportCommand,
+	importCommand,
+	webCommand,
+}
 
+var setupCommand = &command{
+	name:  "setup",
+	args:  "<create|teardown>",
+	short: "Setup the databases used by scrape to store records.",
+	long: `
 Examples:
 
 	'goschedule setup create --config=./config.json': Reads the config and creates several databases for each defined schedule.
 	'goschedule setup teardown --config=./config.json': Drops databases according to each defined schedule's name.
 
-Note that 'goschedule setup teardown' will not work properly if you change the schedules in the JSON config after running 'goschedule setup create'.`
-
-var scrapeHelp string = `Usage:
-
-	goschedule scrape --config=<path to config>
+Note that 'goschedule setup teardown' will not work properly if you change the schedules in the JSON config after running 'goschedule setup create'.`,
+	flags: func(fs *flag.FlagSet) func(config, []string) error {
+		return handleSetup
+	},
+}
 
+var scrapeCommand = &command{
+	name:  "scrape",
+	short: "Scrape the UW time schedule.",
+	long: `
 Scrapes each schedule defined in the config and stores results in databases.
-Expects that 'goschedule setup create' has been run to setup the databases.`
+Expects that 'goschedule setup create' has been run to setup the databases.
+
+Each scrape writes a new generation of the schedule, an application
+database, and flips the switch to serve it once it finishes. Generations
+beyond the number retained by the config are then dropped.
+
+The scraper records each department it completes, once all of its records
+are stored, in the generation it writes to; a scrape in which records failed
+to store is not marked complete. With --resume, a scrape that stopped before finishing is continued from
+the last completed department in the same generation, instead of starting
+over. If the last scrape finished, --resume has no effect.
+
+With --dry-run, each schedule is scraped once and the extracted records and a
+report are written as JSON files to <directory>/<schedule name>, without
+connecting to any database or flipping the switch.
+
+With loopScraper set in the config, schedules are scraped every scraperTimeout
+minutes. A schedule whose end date has passed is only scraped once. A
+schedule that another process, such as 'goschedule web --scheduler', is
+scraping is skipped. A schedule whose switch is pinned is scraped, but its
+switch is not flipped (see 'goschedule help switch').
+
+With --metrics, Prometheus metrics are served at /metrics on <address>, ex.
+":9100": pages fetched and fetch errors, and per schedule the duration,
+skipped departments and errors of the last scrape and the time of the last
+successful switch flip.`,
+	flags: func(fs *flag.FlagSet) func(config, []string) error {
+		var f scrapeFlags
+		fs.BoolVar(&f.dryRun, "dry-run", false, "Write records to files instead of databases.")
+		fs.BoolVar(&f.resume, "resume", false, "Continue an interrupted scrape.")
+		fs.StringVar(&f.out, "out", ".", "Write records with --dry-run to `directory`.")
+		fs.StringVar(&f.metrics, "metrics", "", "Serve metrics on `address`, ex. \":9100\".")
+		return func(conf config, args []string) error {
+			return handleScrape(conf, args, f)
+		}
+	},
+}
 
-var webHelp string = `Usage:
+var diffCommand = &command{
+	name:  "diff",
+	short: "Print the changes found by the last scrape of a schedule.",
+	long: `
+Compares the generation served for a schedule with the newest complete one
+before it and prints the changes: new and cancelled sections, time and room
+moves, instructor and status changes. --from and --to compare other retained
+generations (see 'goschedule switch status').
+
+Changes are also stored by 'goschedule scrape' in the switch database and
+published as an Atom feed per department at /changes/<department>.`,
+	flags: func(fs *flag.FlagSet) func(config, []string) error {
+		var f diffFlags
+		fs.StringVar(&f.schedule, "schedule", "", "Schedule `name` (from config) to compare.")
+		fs.IntVar(&f.from, "from", 0, "Generation `number` to compare from; the one before the live one if unset.")
+		fs.IntVar(&f.to, "to", 0, "Generation `number` to compare to; the live one if unset.")
+		return func(conf config, args []string) error {
+			return handleDiff(conf, args, f)
+		}
+	},
+}
 
-	goschedule web --config=<path to config> --schedule=<schedule name> --fcgi=<port number>|--local=<port number>
+var webCommand = &command{
+	name:  "web",
+	short: "Start the web application to view Go Schedule.",
+	long: `
+Serves the schedule on a port, either over HTTP with --local or through fcgi
+with --fcgi.
 
 Examples:
-	
+
 	'goschedule web --config=./config.json --schedule=aut2013 --local=8080': Starts Go Schedule web app that can be viewed in a browser at localhost:8080.
 	'goschedule web --config=./config.json --schedule=aut2014 --fcgi=9000': Starts Go Schedule web app serving through fcgi on port 9000 (Used with an nginx server).
 
-Note that the flags need to be in the order shown in 'Usage'.`
+The web app serves Prometheus metrics at /metrics: request latency and status
+codes per route, and database query time.
+
+With --scheduler, every schedule in the config with cron expressions is
+scraped at the times they give, as by 'goschedule scrape --resume', while the
+web app runs. A lock in the switch database keeps two scrapers, in this or
+other processes, from scraping the same schedule at once: a run that finds the
+schedule locked fails and waits for its next time. The next and last runs of
+each schedule are shown at /admin/scheduler.
+
+With admin.password set in the config, /admin shows for every schedule in the
+config which generation is live, every retained generation with the report
+of its scrape and the rows of the live one, with buttons to scrape the
+schedule, and flip, roll back, pin or unpin its switch or serve a generation
+as 'goschedule switch' does. The
+/admin pages, /admin/scheduler included, ask for the admin user and
+password, and are not served without admin.password.`,
+	flags: func(fs *flag.FlagSet) func(config, []string) error {
+		var f webFlags
+		fs.IntVar(&f.local, "local", 0, "Local `port` number to serve and listen on.")
+		fs.IntVar(&f.fcgi, "fcgi", 0, "Fcgi `port` number to serve and listen on.")
+		fs.StringVar(&f.schedule, "schedule", "", "Schedule `name` (from config) to serve.")
+		fs.BoolVar(&f.scheduler, "scheduler", false, "Scrape schedules at the times given by their cron expressions in the config.")
+		return func(conf config, args []string) error {
+			return handleWeb(conf, args, f)
+		}
+	},
+}
 
-var dbSetupStatements = make([]string, 7)
+var dbSetupStatements []string
 
 func init() {
-	for i, object := range []interface{}{goschedule.College{}, goschedule.Dept{}, goschedule.Class{}, goschedule.Sect{}} {
-		dbSetupStatements[i] = goschedule.GenerateSchema(object)
+	for _, object := range []interface{}{
+		goschedule.College{},
+		goschedule.Dept{},
+		goschedule.Class{},
+		goschedule.Sect{},
+		goschedule.ClassPrereq{},
+		goschedule.ClassJoint{},
+		goschedule.Instructor{},
+		backend.Checkpoint{},
+	} {
+		dbSetupStatements = append(dbSetupStatements, goschedule.GenerateSchema(object))
 	}
-	dbSetupStatements[4] = "CREATE EXTENSION plpythonu"
-	dbSetupStatements[5] = wordScoreSqlFunc
-	dbSetupStatements[6] = letterScoreSqlFunc
+	dbSetupStatements = append(dbSetupStatements,
+		"CREATE EXTENSION plpythonu",
+		wordScoreSqlFunc,
+		letterScoreSqlFunc,
+	)
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println(usage)
-		os.Exit(1)
-	}
-	var flags []string
-	if len(os.Args) > 2 {
-		flags = os.Args[2:]
-	}
-	switch os.Args[1] {
-	case "help":
-		if len(os.Args) > 2 {
-			switch command := os.Args[2]; command {
-			case "setup":
-				fmt.Println(setupHelp)
-				os.Exit(0)
-			case "scrape":
-				fmt.Println(scrapeHelp)
-				os.Exit(0)
-			case "web":
-				fmt.Println(webHelp)
-				os.Exit(0)
-			default:
-				fmt.Printf("ERROR: help not implemented for %q\n", command)
-				os.Exit(1)
-			}
-		}
-		fmt.Println(usage)
-		os.Exit(0)
-	case "setup":
-		handleSetup(flags)
-	case "scrape":
-		handleScrape(flags)
-	case "web":
-		handleWeb(flags)
-	default:
-		fmt.Println("ERROR: unrecognized arguments")
-		fmt.Println(usage)
-		os.Exit(1)
-	}
+	os.Exit(runCommand(os.Args[1:]))
 }
 
-func handleSetup(args []string) {
-	if len(args) < 1 {
-		fmt.Println("ERROR: not enough arguments")
-		os.Exit(1)
+func handleSetup(conf config, args []string) error {
+	if len(args) != 1 {
+		return usagef("expected one argument, create or teardown")
+	}
+	if args[0] != "create" && args[0] != "teardown" {
+		return usagef("unknown argument %q, expected create or teardown", args[0])
 	}
-	// create or drop databases
-	var command string
-	switch args[0] {
-	case "create":
-		command = "CREATE"
-	case "teardown":
-		command = "DROP"
-	default:
-		fmt.Println("unrecognized argument")
-		os.Exit(1)
-	}
-	// load config
-	conf := parseConfig(args[1:])
-	user := conf.DbLogin["user"]
-	password := conf.DbLogin["user"]
-	dbname := conf.DbLogin["dbname"]
 	// connect to superuser db
-	db, err := sql.Open("postgres", fmt.Sprintf(
-		"user=%s dbname=%s password=%s sslmode=require",
-		user,
-		dbname,
-		password,
-	))
+	db, err := sql.Open("postgres", conf.DbLogin.connString(conf.DbLogin.DbName))
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return fmt.Errorf("connecting to database %s: %v", conf.DbLogin.DbName, err)
 	}
+	defer db.Close()
 	// setup databases for each schedule
 	for _, schedule := range conf.Schedules {
-		for _, statement := range []string{
-			fmt.Sprintf("%s DATABASE goschedule_%s_switch", command, schedule["name"]),
-			fmt.Sprintf("%s DATABASE goschedule_%s_app1", command, schedule["name"]),
-			fmt.Sprintf("%s DATABASE goschedule_%s_app2", command, schedule["name"]),
-		} {
-			if _, err := db.Exec(statement); err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+		if args[0] == "teardown" {
+			if err := teardownSchedule(conf, db, schedule.Name); err != nil {
+				return err
 			}
+			continue
 		}
-		if command == "CREATE" {
-			// load switch schema
-			runSql("postgres", fmt.Sprintf(
-				"user=%s dbname=%s password=%s sslmode=require",
-				user,
-				fmt.Sprintf("goschedule_%s_switch", schedule["name"]),
-				password,
-			), "CREATE TABLE switch_table ( switch_col int)", "INSERT INTO switch_table VALUES (1)")
-			// load app db schemas and python functions
-			for i := 1; i < 3; i++ {
-				runSql("postgres", fmt.Sprintf(
-					"user=%s dbname=%s password=%s sslmode=require",
-					user,
-					fmt.Sprintf("goschedule_%s_app%d", schedule["name"], i),
-					password,
-				), dbSetupStatements...)
+		for _, name := range []string{switchDbName(schedule.Name), appDbName(schedule.Name, 1)} {
+			if _, err := db.Exec("CREATE DATABASE " + name); err != nil {
+				return fmt.Errorf("setting up database %s of %s: %v", name, schedule.Name, err)
 			}
 		}
+		// load switch schema, serving the empty generation 1
+		statements := []string{
+			"CREATE TABLE switch_table (live int)",
+			"INSERT INTO switch_table VALUES (1)",
+			goschedule.GenerateSchema(goschedule.Change{}),
+		}
+		statements = append(statements, shared.SwitchSchema...)
+		statements = append(statements, "INSERT INTO generation (id, created) VALUES (1, now())")
+		if err := runSql("postgres", conf.DbLogin.connString(switchDbName(schedule.Name)), statements...); err != nil {
+			return fmt.Errorf("setting up switch database of %s: %v", schedule.Name, err)
+		}
+		// load app db schemas and python functions
+		if err := runSql("postgres", conf.DbLogin.connString(appDbName(schedule.Name, 1)), dbSetupStatements...); err != nil {
+			return fmt.Errorf("setting up application database 1 of %s: %v", schedule.Name, err)
+		}
 	}
+	return nil
+}
+
+// teardownSchedule drops the databases of every generation of a schedule
+// and its switch database, connected to the server by db.
+func teardownSchedule(conf config, db *sql.DB, schedule string) error {
+	switchDb, err := openSwitchDb(conf, schedule)
+	if err != nil {
+		return fmt.Errorf("connecting to switch database of %s: %v", schedule, err)
+	}
+	ids, err := shared.GenerationIDs(switchDb)
+	switchDb.Close()
+	if err != nil {
+		return fmt.Errorf("reading generations of %s: %v", schedule, err)
+	}
+	var names []string
+	for _, id := range ids {
+		names = append(names, appDbName(schedule, id))
+	}
+	for _, name := range append(names, switchDbName(schedule)) {
+		if _, err := db.Exec("DROP DATABASE IF EXISTS " + name); err != nil {
+			return fmt.Errorf("dropping database %s of %s: %v", name, schedule, err)
+		}
+	}
+	return nil
+}
+
+// scrapeFlags are the flags of the scrape command.
+type scrapeFlags struct {
+	dryRun  bool
+	resume  bool
+	out     string
+	metrics string
 }
 
-func handleScrape(args []string) {
-	conf := parseConfig(args)
-	user := conf.DbLogin["user"]
-	password := conf.DbLogin["password"]
-	dbname := conf.DbLogin["dbname"]
-	for {
+func handleScrape(conf config, args []string, f scrapeFlags) error {
+	if len(args) > 0 {
+		return usagef("unexpected arguments %q", args)
+	}
+	if f.metrics != "" {
+		serveMetrics(f.metrics)
+	}
+	if f.dryRun {
+		for _, schedule := range conf.Schedules {
+			dir := filepath.Join(f.out, schedule.Name)
+			log := logger.With("schedule", schedule.Name)
+			log.Info("scraping to files", "url", schedule.URL, "dir", dir)
+			store := backend.NewDirStore(dir)
+			report := backend.Scrape(schedule.URL, conf.DepartmentDescriptionIndex, store, log)
+			if err := store.Write(report); err != nil {
+				return fmt.Errorf("writing records to %s: %v", dir, err)
+			}
+			logReport(log, report)
+		}
+		return nil
+	}
+	for iteration := 0; ; iteration++ {
 		// scrape for each schedule specified in config
 		for _, schedule := range conf.Schedules {
-			// connect to switch db
-			switchDb, err := sql.Open("postgres", fmt.Sprintf(
-				"user=%s dbname=%s password=%s sslmode=require",
-				user,
-				fmt.Sprintf("goschedule_%s_switch", schedule["name"]),
-				password,
-			))
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+			log := logger.With("schedule", schedule.Name)
+			// the time schedule of a quarter that has ended no longer changes
+			if iteration > 0 && schedule.ended(time.Now()) {
+				log.Debug("schedule skipped", "reason", "quarter ended", "end", schedule.End)
+				continue
 			}
-			appNum, err := shared.GetSwitch(switchDb)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+			err := scrapeSchedule(context.Background(), conf, schedule, f.resume, "scraper", log)
+			if err == shared.ErrLocked {
+				log.Warn("schedule skipped", "reason", "locked", "err", err)
+				continue
 			}
-			// reset app db
-			runSql("postgres", fmt.Sprintf(
-				"user=%s dbname=%s password=%s sslmode=require",
-				user,
-				dbname,
-				password),
-				fmt.Sprintf("DROP DATABASE goschedule_%s_app%d", schedule["name"], appNum),
-				fmt.Sprintf("CREATE DATABASE goschedule_%s_app%d", schedule["name"], appNum))
-			// connect to app db
-			appDb, err := sql.Open("postgres", fmt.Sprintf(
-				"user=%s dbname=%s password=%s sslmode=require",
-				conf.DbLogin["user"],
-				fmt.Sprintf("goschedule_%s_app%d", schedule["name"], appNum),
-				conf.DbLogin["password"],
-			))
 			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-			// load app db with schemas
-			runSql("postgres", fmt.Sprintf(
-				"user=%s dbname=%s password=%s sslmode=require",
-				user,
-				fmt.Sprintf("goschedule_%s_app%d", schedule["name"], appNum),
-				password,
-			), dbSetupStatements...)
-			// start scrape
-			start := time.Now()
-			fmt.Printf("Scraping %q using application database %d\n", schedule["url"], appNum)
-			backend.Scrape(schedule["url"], conf.DepartmentDescriptionIndex, appDb)
-			fmt.Println("Time taken:", time.Since(start))
-			// flip db switch
-			if err := flipSwitch(switchDb); err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+				return fmt.Errorf("scraping %s: %v", schedule.Name, err)
 			}
-			switchDb.Close()
-			fmt.Printf("Scrape for %q done\n", schedule["url"])
-			// close connection to app db
-			appDb.Close()
 		}
 		if !conf.LoopScraper {
-			break
+			return nil
 		}
+		// only the first iteration resumes
+		f.resume = false
 		time.Sleep(time.Duration(conf.ScraperTimeout) * time.Minute)
 	}
 }
 
-func handleWeb(flags []string) {
-	if len(flags) < 3 {
-		fmt.Println("ERROR: not enough arguments")
-		os.Exit(1)
-	}
-	conf := parseConfig(flags[0:1])
-	webFlags := flag.NewFlagSet("flags", flag.ContinueOnError)
-	var local int
-	var fcgi int
-	var schedule string
-	webFlags.IntVar(&local, "local", 0, "Local port number to serve and listen on.")
-	webFlags.IntVar(&fcgi, "fcgi", 0, "Fcgi port number to serve and listen on.")
-	webFlags.StringVar(&schedule, "schedule", "", "Name of the schedule (from config) to serve.")
-	webFlags.Parse(flags[1:])
-	var scheduleName string
-	for _, s := range conf.Schedules {
-		if schedule == s["name"] {
-			scheduleName = schedule
+// scrapeSchedule scrapes a schedule into a new generation, records the
+// changes from the live one, flips the switch to serve the new one, unless
+// it is pinned, and drops expired generations. With resume, an interrupted
+// scrape into the newest generation is continued instead. actor is recorded
+// in the audit log of the switch. It returns shared.ErrLocked if another
+// process is scraping the schedule.
+func scrapeSchedule(ctx context.Context, conf config, schedule scheduleConfig, resume bool, actor string, log *logging.Logger) error {
+	switchDb, unlock, err := lockSchedule(ctx, conf, schedule.Name)
+	if err != nil {
+		return err
+	}
+	defer switchDb.Close()
+	defer unlock()
+	return scrapeLocked(conf, schedule, switchDb, resume, actor, log)
+}
+
+// startScrape starts scraping a schedule in the background, as by
+// scrapeSchedule with resume, once it has taken the scrape lock. It returns
+// shared.ErrLocked if another process is scraping the schedule.
+func startScrape(conf config, name, actor string) error {
+	schedule, ok := conf.schedule(name)
+	if !ok {
+		return fmt.Errorf("cannot find schedule %q in config", name)
+	}
+	switchDb, unlock, err := lockSchedule(context.Background(), conf, name)
+	if err != nil {
+		return err
+	}
+	log := logger.With("schedule", name)
+	go func() {
+		defer switchDb.Close()
+		defer unlock()
+		// Scrape panics if the time schedule cannot be fetched
+		defer func() {
+			if err := recover(); err != nil {
+				log.Error("scraping", "err", err)
+			}
+		}()
+		if err := scrapeLocked(conf, schedule, switchDb, true, actor, log); err != nil {
+			log.Error("scraping", "err", err)
+		}
+	}()
+	return nil
+}
+
+// lockSchedule connects to the switch database of a schedule and takes its
+// scrape lock.
+func lockSchedule(ctx context.Context, conf config, schedule string) (switchDb *sql.DB, unlock func(), err error) {
+	switchDb, err = openSwitchDb(conf, schedule)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to switch database: %v", err)
+	}
+	unlock, err = shared.LockScrape(ctx, switchDb)
+	if err != nil {
+		switchDb.Close()
+		return nil, nil, err
+	}
+	return switchDb, unlock, nil
+}
+
+// scrapeLocked is scrapeSchedule once the scrape lock is held.
+func scrapeLocked(conf config, schedule scheduleConfig, switchDb *sql.DB, resume bool, actor string, log *logging.Logger) error {
+	state, err := shared.State(switchDb, appDbOpener(conf, schedule.Name))
+	if err != nil {
+		return fmt.Errorf("reading switch: %v", err)
+	}
+	// create a generation, unless resuming an interrupted scrape
+	appNum := state.Newest().ID
+	if resume && appNum > state.Live && resumable(conf, schedule.Name, appNum) {
+		log.Info("resuming scrape", "app", appNum)
+	} else if appNum, err = newGeneration(conf, schedule.Name, switchDb); err != nil {
+		return fmt.Errorf("creating generation: %v", err)
+	}
+	// connect to app db
+	appDb, err := openAppDb(conf, schedule.Name, appNum)
+	if err != nil {
+		return fmt.Errorf("connecting to application database %d: %v", appNum, err)
+	}
+	defer appDb.Close()
+	store, err := backend.NewDbStore(appDb)
+	if err != nil {
+		return fmt.Errorf("connecting to application database %d: %v", appNum, err)
+	}
+	// start scrape
+	log.Info("scraping", "url", schedule.URL, "app", appNum)
+	report := backend.Scrape(schedule.URL, conf.DepartmentDescriptionIndex, store, log.With("app", appNum))
+	logReport(log, report)
+	observeScrape(schedule.Name, report)
+	if err := shared.SaveReport(switchDb, appNum, report); err != nil {
+		log.Error("saving report", "err", err)
+	}
+	// serve only a finished scrape; an unfinished one is left to be resumed
+	complete, err := shared.Complete(appDb)
+	if err != nil {
+		return fmt.Errorf("reading checkpoints of generation %d: %v", appNum, err)
+	}
+	if !complete {
+		log.Warn("scrape incomplete, not flipping", "url", schedule.URL, "scraped", appNum)
+		return collectGenerations(conf, schedule.Name, switchDb, log)
+	}
+	// flip db switch
+	err = shared.FlipSwitch(switchDb, appNum, actor, "scrape", "")
+	switch err {
+	case nil:
+		observeFlip(schedule.Name)
+		// record changes from the database served until now; while pinned,
+		// that is the same database on every scrape, so wait for the flip
+		if err := recordChanges(log, conf, schedule.Name, state.Live, appDb, switchDb); err != nil {
+			log.Error("recording changes", "err", err)
+		}
+		log.Info("scrape done", "url", schedule.URL, "served", appNum)
+	case shared.ErrPinned:
+		log.Warn("switch pinned, not flipping", "url", schedule.URL, "scraped", appNum)
+	default:
+		return fmt.Errorf("flipping switch: %v", err)
+	}
+	return collectGenerations(conf, schedule.Name, switchDb, log)
+}
+
+// collectGenerations drops the generations of a schedule beyond the number
+// retained by the config. The caller holds the scrape lock.
+func collectGenerations(conf config, schedule string, switchDb *sql.DB, log *logging.Logger) error {
+	state, err := shared.State(switchDb, appDbOpener(conf, schedule))
+	if err != nil {
+		return fmt.Errorf("reading switch: %v", err)
+	}
+	// a generation that cannot be reached may be a rollback target
+	if err := state.Err(); err != nil {
+		return fmt.Errorf("not dropping generations: %v", err)
+	}
+	for _, appNum := range state.Expired(conf.Generations) {
+		if err := runSql("postgres", conf.DbLogin.connString(conf.DbLogin.DbName), "DROP DATABASE IF EXISTS "+appDbName(schedule, appNum)); err != nil {
+			return fmt.Errorf("dropping generation %d: %v", appNum, err)
 		}
+		if err := shared.RemoveGeneration(switchDb, appNum); err != nil {
+			return fmt.Errorf("dropping generation %d: %v", appNum, err)
+		}
+		log.Info("generation dropped", "app", appNum)
 	}
-	if scheduleName == "" {
-		fmt.Printf("ERROR: cannot find schedule name %q in config\n", schedule)
-		os.Exit(1)
+	return nil
+}
+
+// webFlags are the flags of the web command.
+type webFlags struct {
+	local     int
+	fcgi      int
+	schedule  string
+	scheduler bool
+}
+
+func handleWeb(conf config, args []string, f webFlags) error {
+	if len(args) > 0 {
+		return usagef("unexpected arguments %q", args)
+	}
+	schedule, local, fcgi := f.schedule, f.local, f.fcgi
+	if _, ok := conf.schedule(schedule); !ok {
+		return usagef("cannot find schedule %q in config", schedule)
 	}
 	if fcgi != 0 && local != 0 {
-		fmt.Println("ERROR: cannot set both --fcgi and --local flags")
-		os.Exit(1)
-	}
-	user := conf.DbLogin["user"]
-	password := conf.DbLogin["password"]
-	dbSwitch, err := sql.Open("postgres", fmt.Sprintf(
-		"user=%s dbname=%s password=%s sslmode=require",
-		user,
-		fmt.Sprintf("goschedule_%s_switch", schedule),
-		password,
-	))
+		return usagef("cannot set both --fcgi and --local flags")
+	}
+	if fcgi == 0 && local == 0 {
+		return usagef("missing --fcgi or --local flag")
+	}
+	dbSwitch, err := openSwitchDb(conf, schedule)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return fmt.Errorf("connecting to switch database of %s: %v", schedule, err)
+	}
+	appDbConnString := func(appNum int) string {
+		return conf.DbLogin.connString(appDbName(schedule, appNum))
+	}
+	admin, err := adminConfig(conf)
+	if err != nil {
+		return fmt.Errorf("connecting to switch databases: %v", err)
+	}
+	if f.scheduler {
+		if admin.Scheduler, err = scrapeScheduler(conf); err != nil {
+			return err
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go admin.Scheduler.Run(ctx)
 	}
-	appDbConnString := fmt.Sprintf("user=%s dbname=goschedule_%s_app%%d password=%s sslmode=require",
-		user,
-		schedule,
-		password,
-	)
 	if local != 0 {
-		fmt.Printf("Go Schedule frontend started locally on port %d\n", local)
-		if err := frontend.Serve(appDbConnString, dbSwitch, true, conf.FrontendRoot, local); err != nil {
-			fmt.Printf("ERROR in handleWeb: %v\n", err)
-			os.Exit(1)
+		log := logger.With("schedule", schedule, "port", local)
+		log.Info("serving locally")
+		if err := frontend.Serve(appDbConnString, dbSwitch, true, conf.FrontendRoot, local, admin, log); err != nil {
+			return fmt.Errorf("serving on port %d: %v", local, err)
 		}
 	}
 	if fcgi != 0 {
-		fmt.Printf("Go Schedule frontend serving through fcgi on port %d\n", fcgi)
-		if err := frontend.Serve(appDbConnString, dbSwitch, false, conf.FrontendRoot, fcgi); err != nil {
-			fmt.Printf("ERROR in handleWeb: %v\n", err)
-			os.Exit(1)
+		log := logger.With("schedule", schedule, "port", fcgi)
+		log.Info("serving through fcgi")
+		if err := frontend.Serve(appDbConnString, dbSwitch, false, conf.FrontendRoot, fcgi, admin, log); err != nil {
+			return fmt.Errorf("serving on port %d: %v", fcgi, err)
 		}
 	}
+	return nil
 }
 
-// config represents a JSON config file marshalled into a struct.
-type config struct {
-	FrontendRoot               string
-	DepartmentDescriptionIndex string
-	ScraperTimeout             int
-	LoopScraper                bool
-	DbLogin                    map[string]string
-	Schedules                  []map[string]string
+// adminConfig returns the configuration of the /admin pages, which manage
+// every schedule of the config.
+func adminConfig(conf config) (*frontend.Admin, error) {
+	admin := &frontend.Admin{
+		User:     conf.Admin.User,
+		Password: conf.Admin.Password,
+		Scrape: func(schedule, actor string) error {
+			return startScrape(conf, schedule, actor)
+		},
+	}
+	for _, schedule := range conf.Schedules {
+		name := schedule.Name
+		switchDb, err := openSwitchDb(conf, name)
+		if err != nil {
+			return nil, err
+		}
+		admin.Terms = append(admin.Terms, frontend.Term{
+			Name:     name,
+			SwitchDb: switchDb,
+			AppConn: func(appNum int) string {
+				return conf.DbLogin.connString(appDbName(name, appNum))
+			},
+		})
+	}
+	return admin, nil
 }
 
-func findScheduleInConfig(name string, schedules []map[string]string) bool {
-	for _, s := range schedules {
-		if name == s["name"] {
-			return true
+// scrapeScheduler returns a scheduler with a job per schedule of the config
+// with cron expressions. The jobs resume interrupted scrapes.
+func scrapeScheduler(conf config) (*scheduler.Scheduler, error) {
+	jobs := scheduler.New(logger.With("component", "scheduler"))
+	for _, schedule := range conf.Schedules {
+		if len(schedule.Cron) == 0 {
+			continue
+		}
+		schedule := schedule
+		log := logger.With("schedule", schedule.Name)
+		if err := jobs.Add(schedule.Name, schedule.Cron, func(ctx context.Context) error {
+			if schedule.ended(time.Now()) {
+				log.Debug("schedule skipped", "reason", "quarter ended", "end", schedule.End)
+				return nil
+			}
+			return scrapeSchedule(ctx, conf, schedule, true, "scheduler", log)
+		}); err != nil {
+			return nil, err
 		}
 	}
-	return false
+	return jobs, nil
 }
 
-// parseConfig will use the given args to try to load a file from the `--config` flag.
-// If the config flag is not set, or if it cannot read the file path, or it encounters
-// an error when unmarshalling the config from JSON, it will call os.Exit(1).
-// Else, it will return a config struct.
-func parseConfig(args []string) config {
-	flagSet := flag.NewFlagSet("flags", flag.ContinueOnError)
-	configPathPtr := flagSet.String("config", "", "Path to a JSON formatted config file.")
-	flagSet.Parse(args)
-	configPath := *configPathPtr
-	if configPath == "" {
-		fmt.Println("missing `--config` flag")
-		os.Exit(1)
+// diffFlags are the flags of the diff command.
+type diffFlags struct {
+	schedule string
+	from     int
+	to       int
+}
+
+func handleDiff(conf config, args []string, f diffFlags) error {
+	if len(args) > 0 {
+		return usagef("unexpected arguments %q", args)
+	}
+	schedule := f.schedule
+	if _, ok := conf.schedule(schedule); !ok {
+		return usagef("cannot find schedule %q in config", schedule)
+	}
+	switchDb, err := openSwitchDb(conf, schedule)
+	if err != nil {
+		return fmt.Errorf("connecting to switch database of %s: %v", schedule, err)
+	}
+	defer switchDb.Close()
+	state, err := shared.State(switchDb, appDbOpener(conf, schedule))
+	if err != nil {
+		return fmt.Errorf("reading switch of %s: %v", schedule, err)
+	}
+	if f.to == 0 {
+		f.to = state.Live
+	}
+	if f.from == 0 {
+		if f.from, err = state.Previous(); err != nil {
+			return fmt.Errorf("finding generation of %s to compare from: %v", schedule, err)
+		}
+	}
+	for _, appNum := range []int{f.from, f.to} {
+		if _, ok := state.Generation(appNum); !ok {
+			return usagef("no generation %d of schedule %s", appNum, schedule)
+		}
+	}
+	oldDb, err := openAppDb(conf, schedule, f.from)
+	if err != nil {
+		return fmt.Errorf("connecting to application database %d: %v", f.from, err)
+	}
+	defer oldDb.Close()
+	newDb, err := openAppDb(conf, schedule, f.to)
+	if err != nil {
+		return fmt.Errorf("connecting to application database %d: %v", f.to, err)
 	}
-	rawConfig, err := ioutil.ReadFile(configPath)
+	defer newDb.Close()
+	changes, err := goschedule.DiffDatabases(oldDb, newDb, time.Now())
 	if err != nil {
-		fmt.Printf("error reading config at %s\n", configPath)
-		os.Exit(1)
+		return fmt.Errorf("comparing databases of %s: %v", schedule, err)
 	}
-	parsedConfig := config{}
-	if err := json.Unmarshal(rawConfig, &parsedConfig); err != nil {
-		fmt.Printf("error parsing config file at %s to JSON\n", configPath)
-		os.Exit(1)
+	for _, change := range changes {
+		fmt.Printf("%-20s %s\n", change.Kind, change.Summary())
 	}
-	return parsedConfig
+	fmt.Printf("%d changes\n", len(changes))
+	return nil
 }
 
-// runSql is a convenience method that  connects to a database with the given
-// driver and connection string and executes SQL statements. If it encounters
-// an error, it prints the error and exits with status code 1.
-func runSql(driver, connection string, statements ...string) {
-	db, err := sql.Open(driver, connection)
+// switchDbName returns the name of the switch database of a schedule.
+func switchDbName(schedule string) string {
+	return fmt.Sprintf("goschedule_%s_switch", schedule)
+}
+
+// appDbName returns the name of the application database of generation
+// appNum of a schedule.
+func appDbName(schedule string, appNum int) string {
+	return fmt.Sprintf("goschedule_%s_app%d", schedule, appNum)
+}
+
+// openSwitchDb connects to the switch database of a schedule.
+func openSwitchDb(conf config, schedule string) (*sql.DB, error) {
+	return sql.Open("postgres", conf.DbLogin.connString(switchDbName(schedule)))
+}
+
+// newGeneration creates the application database of a new generation of a
+// schedule and loads it with schemas.
+func newGeneration(conf config, schedule string, switchDb *sql.DB) (int, error) {
+	appNum, err := shared.NewGeneration(switchDb)
+	if err != nil {
+		return 0, err
+	}
+	if err := runSql("postgres", conf.DbLogin.connString(conf.DbLogin.DbName), "CREATE DATABASE "+appDbName(schedule, appNum)); err != nil {
+		return 0, err
+	}
+	if err := runSql("postgres", conf.DbLogin.connString(appDbName(schedule, appNum)), dbSetupStatements...); err != nil {
+		return 0, err
+	}
+	return appNum, nil
+}
+
+// resumable reports whether application database appNum of a schedule holds
+// an interrupted scrape: it has checkpoints, but not backend.DoneKey.
+func resumable(conf config, schedule string, appNum int) bool {
+	db, err := openAppDb(conf, schedule, appNum)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return false
 	}
 	defer db.Close()
-	for _, statement := range statements {
-		if _, err := db.Exec(statement); err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
+	var checkpoints, done int
+	err = db.QueryRow(
+		"SELECT count(*), count(CASE WHEN key = $1 THEN 1 END) FROM checkpoint",
+		backend.DoneKey,
+	).Scan(&checkpoints, &done)
+	return err == nil && checkpoints > 0 && done == 0
+}
+
+// openAppDb connects to application database appNum of a schedule.
+func openAppDb(conf config, schedule string, appNum int) (*sql.DB, error) {
+	return sql.Open("postgres", conf.DbLogin.connString(appDbName(schedule, appNum)))
+}
+
+// appDbOpener returns a function connecting to the application databases of
+// a schedule, as taken by shared.State.
+func appDbOpener(conf config, schedule string) func(appNum int) (*sql.DB, error) {
+	return func(appNum int) (*sql.DB, error) {
+		return openAppDb(conf, schedule, appNum)
 	}
 }
 
-// flipSwitch changes the value stored in the 'switch db' from 1 to 2
-// or from 2 to 1.
-func flipSwitch(db *sql.DB) error {
-	currentSwitch, err := shared.GetSwitch(db)
+// recordChanges compares application database servedNum of a schedule with
+// newDb, which has just been scraped, and stores the changes in switchDb.
+func recordChanges(log *logging.Logger, conf config, schedule string, servedNum int, newDb, switchDb *sql.DB) error {
+	servedDb, err := openAppDb(conf, schedule, servedNum)
 	if err != nil {
 		return err
 	}
-	var newSwitch int
-	if currentSwitch == 1 {
-		newSwitch = 2
-	} else {
-		newSwitch = 1
+	defer servedDb.Close()
+	changes, err := goschedule.DiffDatabases(servedDb, newDb, time.Now())
+	if err != nil {
+		return err
 	}
-	query := fmt.Sprintf(
-		"UPDATE switch_table SET switch_col = %d WHERE switch_col = %d",
-		newSwitch,
-		currentSwitch,
-	)
-	_, err = db.Exec(query)
+	for _, change := range changes {
+		if err := goschedule.Insert(switchDb, change); err != nil {
+			return err
+		}
+	}
+	log.Info("changes recorded", "changes", len(changes))
+	return nil
+}
+
+// logger is the logger of every command, configured by loadConfigFlag.
+var logger = logging.Default
+
+// logReport logs the summary of a scrape.
+func logReport(log *logging.Logger, report *backend.Report) {
+	log.Info("scrape finished",
+		"colleges", report.Colleges,
+		"depts", len(report.Depts),
+		"skipped", len(report.Skipped),
+		"resumed", report.Resumed,
+		"classes", report.Classes,
+		"sections", report.Sects,
+		"instructors", report.Instructors,
+		"descriptions", report.Descriptions,
+		"errors", len(report.Errors),
+		"duration", report.Finished.Sub(report.Started))
+}
+
+// runSql is a convenience method that  connects to a database with the given
+// driver and connection string and executes SQL statements. It stops at the
+// first error.
+func runSql(driver, connection string, statements ...string) error {
+	db, err := sql.Open(driver, connection)
 	if err != nil {
 		return err
 	}
+	defer db.Close()
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("%v, statement: %q", err, statement)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+)
+
+// fakeDriver opens fakeConns, which run no queries but record the options
+// of their transactions, and are valid until broken.
+type fakeDriver struct {
+	opened int
+	conns  []*fakeConn
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.opened++
+	conn := &fakeConn{}
+	d.conns = append(d.conns, conn)
+	return conn, nil
+}
+
+type fakeConn struct {
+	broken bool
+	txOpts []driver.TxOptions
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("Begin called instead of BeginTx")
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.txOpts = append(c.txOpts, opts)
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return fakeRows{}, nil
+}
+
+func (c *fakeConn) IsValid() bool {
+	return !c.broken
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string              { return nil }
+func (fakeRows) Close() error                   { return nil }
+func (fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+func TestTimedDriver(t *testing.T) {
+	fake := &fakeDriver{}
+	var observed int
+	sql.Register("timedfake", TimedDriver(fake, func(float64) { observed++ }))
+	db, err := sql.Open("timedfake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if observed != 1 {
+		t.Errorf("got %d queries observed, expected 1", observed)
+	}
+	// transaction options reach the connection
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := fake.conns[0].txOpts
+	if len(opts) != 1 || opts[0].Isolation != driver.IsolationLevel(sql.LevelSerializable) || !opts[0].ReadOnly {
+		t.Errorf("got transaction options %+v", opts)
+	}
+	// a connection that is no longer valid is dropped from the pool when
+	// released
+	fake.conns[0].broken = true
+	tx.Rollback()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if fake.opened != 2 {
+		t.Errorf("got %d connections opened, expected 2", fake.opened)
+	}
+}
@@ -0,0 +1,262 @@
+// Package metrics keeps counters, gauges and histograms and exposes them in
+// the Prometheus text exposition format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds of histogram buckets, in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// A Registry holds metrics to expose.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// Default is the Registry metrics are created in.
+var Default = &Registry{}
+
+// metric is a metric family with a value per combination of label values.
+type metric interface {
+	name() string
+	write(b *bytes.Buffer)
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.metrics {
+		if other.name() == m.name() {
+			panic(fmt.Sprintf("metrics: %q registered twice", m.name()))
+		}
+	}
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo writes every metric of r in the Prometheus text format, sorted by
+// name.
+func (r *Registry) WriteTo(b *bytes.Buffer) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+	for _, m := range metrics {
+		m.write(b)
+	}
+}
+
+// Handler serves the metrics of Default.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b bytes.Buffer
+		Default.WriteTo(&b)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(b.Bytes())
+	})
+}
+
+// family holds what all kinds of metrics have in common.
+type family struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+	mu         sync.Mutex
+}
+
+func (f *family) name() string {
+	return f.metricName
+}
+
+// key joins label values into a map key, checking their number.
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, got values %v", f.metricName, f.labels, values))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelString formats label names and values, plus extra pairs, as
+// `{name="value",...}`, or "" if there are none.
+func (f *family) labelString(key string, extra ...string) string {
+	var pairs []string
+	if len(f.labels) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", f.labels[i], value))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", extra[i], extra[i+1]))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (f *family) writeHeader(b *bytes.Buffer) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", f.metricName, f.help, f.metricName, f.kind)
+}
+
+// sortedKeys returns the keys of values in order.
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// A Counter is a value that only goes up, per combination of label values.
+type Counter struct {
+	family
+	values map[string]float64
+}
+
+// NewCounter creates a Counter in Default.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{family{metricName: name, help: help, kind: "counter", labels: labels}, make(map[string]float64)}
+	Default.register(c)
+	return c
+}
+
+// Inc adds 1 to the counter with the given label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter with the given
+// label values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+}
+
+func (c *Counter) write(b *bytes.Buffer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(b)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(b, "%s%s %s\n", c.metricName, c.labelString(key), formatFloat(c.values[key]))
+	}
+}
+
+// A Gauge is a value that can go up and down, per combination of label
+// values.
+type Gauge struct {
+	family
+	values map[string]float64
+}
+
+// NewGauge creates a Gauge in Default.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{family{metricName: name, help: help, kind: "gauge", labels: labels}, make(map[string]float64)}
+	Default.register(g)
+	return g
+}
+
+// Set sets the gauge with the given label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	key := g.key(labelValues)
+	g.mu.Lock()
+	g.values[key] = v
+	g.mu.Unlock()
+}
+
+func (g *Gauge) write(b *bytes.Buffer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.writeHeader(b)
+	for _, key := range sortedKeys(g.values) {
+		fmt.Fprintf(b, "%s%s %s\n", g.metricName, g.labelString(key), formatFloat(g.values[key]))
+	}
+}
+
+// A Histogram counts observations in buckets, per combination of label
+// values.
+type Histogram struct {
+	family
+	buckets []float64
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates a Histogram in Default with the given bucket upper
+// bounds, or DefaultBuckets if buckets is nil.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{family{metricName: name, help: help, kind: "histogram", labels: labels}, buckets, make(map[string]*histogramSeries)}
+	Default.register(h)
+	return h
+}
+
+// Observe adds an observation v to the histogram with the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *Histogram) write(b *bytes.Buffer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(b)
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(b, "%s_bucket%s %d\n", h.metricName, h.labelString(key, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", h.metricName, h.labelString(key, "le", "+Inf"), s.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", h.metricName, h.labelString(key), formatFloat(s.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", h.metricName, h.labelString(key), s.count)
+	}
+}
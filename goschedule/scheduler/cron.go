@@ -0,0 +1,216 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Cron is a parsed cron expression: five fields giving the minutes, hours,
+// days of the month, months and days of the week at which a job runs, ex.
+// "*/10 * 1-14 1,4,9 *" for every 10 minutes in the first two weeks of
+// January, April and September.
+//
+// Each field is "*", a number, a range "a-b", or either of the latter with
+// a step "/n", or a comma separated list of those. Months and days of the
+// week may be given by their first three letters ("jan", "mon"); Sunday is
+// 0 or 7. As in cron, if both the day of the month and the day of the week
+// are restricted, a time matching either runs the job; a field starting
+// with "*", such as "*/2", is not restricted, so that the other must match
+// too.
+//
+// Times are wall clock times in the location of the time passed to Next. A
+// time skipped when clocks are set forward runs as much later as they are
+// set forward, and a time repeated when they are set back runs the first
+// time.
+//
+// The shortcuts @yearly, @monthly, @weekly, @daily (or @midnight) and
+// @hourly are also accepted.
+type Cron struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64 // bit i is set if value i matches
+	domAny, dowAny                bool   // the field starts with "*"
+}
+
+var cronShortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// cronField describes the values of a field of a cron expression.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // names of the values from min, if any
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59, nil},
+	{"hour", 0, 23, nil},
+	{"day of month", 1, 31, nil},
+	{"month", 1, 12, monthNames},
+	{"day of week", 0, 7, dayNames},
+}
+
+// ParseCron parses a cron expression.
+func ParseCron(expr string) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if shortcut, ok := cronShortcuts[strings.ToLower(spec)]; ok {
+		spec = shortcut
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q: expected %d fields, found %d", expr, len(cronFields), len(fields))
+	}
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := cronFields[i].parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %v", expr, err)
+		}
+		sets[i] = set
+	}
+	c := &Cron{
+		expr:   expr,
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}
+	// Sunday is both 0 and 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+// parse returns the set of values matched by a field.
+func (f cronField) parse(s string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(s, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%s: invalid step in %q", f.name, part)
+			}
+			rangePart, step = part[:i], n
+		}
+		low, high := f.min, f.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = f.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// "a/n" means from a to the maximum
+				high = f.max
+			}
+			if high < low {
+				return 0, fmt.Errorf("%s: empty range %q", f.name, rangePart)
+			}
+		}
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// value parses a value of a field, a number or a name.
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s: %q is not a value from %d to %d", f.name, s, f.min, f.max)
+	}
+	return n, nil
+}
+
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// matchesDay reports whether the job runs on the day of t.
+func (c *Cron) matchesDay(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// cronHorizon bounds the search for the next time of an expression that
+// matches rarely or never, such as "0 0 30 2 *".
+const cronHorizon = 5 * 366 * 24 * time.Hour
+
+// Next returns the first time after t, in the location of t, at which the
+// job runs, or the zero time if there is none within five years.
+func (c *Cron) Next(t time.Time) time.Time {
+	// the wall clock times of the location are searched as UTC times,
+	// which have no daylight saving time transitions
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC).Add(time.Minute)
+	limit := wall.Add(cronHorizon)
+	for wall.Before(limit) {
+		switch {
+		case c.month&(1<<uint(wall.Month())) == 0:
+			wall = time.Date(wall.Year(), wall.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchesDay(wall):
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day()+1, 0, 0, 0, 0, time.UTC)
+		case c.hour&(1<<uint(wall.Hour())) == 0:
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour()+1, 0, 0, 0, time.UTC)
+		case c.minute&(1<<uint(wall.Minute())) == 0:
+			wall = wall.Add(time.Minute)
+		default:
+			next := at(wall, t.Location())
+			// the first of a repeated time may be before t
+			if next.After(t) {
+				return next
+			}
+			wall = wall.Add(time.Minute)
+		}
+	}
+	return time.Time{}
+}
+
+// at returns the time at which the clocks of loc show the wall clock time
+// wall, given as a UTC time. A time skipped when clocks are set forward is
+// moved forward by as much as they are.
+func at(wall time.Time, loc *time.Location) time.Time {
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, loc)
+	if t.Hour() == wall.Hour() && t.Minute() == wall.Minute() {
+		return t
+	}
+	// time.Date gives the time with the offset from before or after the
+	// change, whose clocks show the time before or after wall; the other
+	// offset gives the other
+	_, offset := t.Zone()
+	other := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+	if other.After(t) {
+		return other
+	}
+	return t
+}
@@ -0,0 +1,202 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	testSet := []struct {
+		expr string
+		err  bool
+	}{
+		{"* * * * *", false},
+		{"*/10 * 1-14 1,4,9 *", false},
+		{"0 9-17/2 * jan-mar mon-fri", false},
+		{"0 0 * * 7", false},
+		{"@daily", false},
+		{"@WEEKLY", false},
+		{"* * * *", true},
+		{"* * * * * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"5-1 * * * *", true},
+		{"*/0 * * * *", true},
+		{"*/x * * * *", true},
+		{"* * * foo *", true},
+		{"@never", true},
+	}
+	for _, test := range testSet {
+		if _, err := ParseCron(test.expr); (err != nil) != test.err {
+			t.Errorf("ParseCron(%q): got error %v", test.expr, err)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2024, 5, 15, 10, 7, 30, 0, time.UTC)
+	testSet := []struct {
+		name     string
+		expr     string
+		from     time.Time
+		expected []time.Time // the next times in turn; the zero time for none
+	}{
+		{"every minute", "* * * * *", from, []time.Time{
+			time.Date(2024, 5, 15, 10, 8, 0, 0, time.UTC),
+			time.Date(2024, 5, 15, 10, 9, 0, 0, time.UTC),
+		}},
+		{"minute step", "*/15 * * * *", from, []time.Time{
+			time.Date(2024, 5, 15, 10, 15, 0, 0, time.UTC),
+			time.Date(2024, 5, 15, 10, 30, 0, 0, time.UTC),
+		}},
+		{"step from a value", "50/5 * * * *", from, []time.Time{
+			time.Date(2024, 5, 15, 10, 50, 0, 0, time.UTC),
+			time.Date(2024, 5, 15, 10, 55, 0, 0, time.UTC),
+			time.Date(2024, 5, 15, 11, 50, 0, 0, time.UTC),
+		}},
+		{"hour range with step", "0 9-17/4 * * *", from, []time.Time{
+			time.Date(2024, 5, 15, 13, 0, 0, 0, time.UTC),
+			time.Date(2024, 5, 15, 17, 0, 0, 0, time.UTC),
+			time.Date(2024, 5, 16, 9, 0, 0, 0, time.UTC),
+		}},
+		{"list", "0 0 1,15 * *", from, []time.Time{
+			time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC),
+		}},
+		{"month and day names", "0 6 * sep-oct MON", from, []time.Time{
+			time.Date(2024, 9, 2, 6, 0, 0, 0, time.UTC),
+			time.Date(2024, 9, 9, 6, 0, 0, 0, time.UTC),
+		}},
+		{"Sunday as 7", "0 0 * * 7", from, []time.Time{
+			time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 5, 26, 0, 0, 0, 0, time.UTC),
+		}},
+		{"Sunday as 0", "0 0 * * 0", from, []time.Time{
+			time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC),
+		}},
+		{"day of month or day of week", "0 0 13 * fri", from, []time.Time{
+			time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 5, 24, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 6, 13, 0, 0, 0, 0, time.UTC),
+		}},
+		{"day of month step and day of week", "0 0 */2 * 1", from, []time.Time{
+			// Mondays on odd days of the month
+			time.Date(2024, 5, 27, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 6, 17, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		}},
+		{"day of month and day of week step", "0 0 20 * */3", from, []time.Time{
+			// the 20th on Sundays, Wednesdays and Saturdays
+			time.Date(2024, 7, 20, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 10, 20, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 11, 20, 0, 0, 0, 0, time.UTC),
+		}},
+		{"@yearly", "@yearly", from, []time.Time{
+			time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		}},
+		{"@monthly", "@monthly", from, []time.Time{
+			time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		}},
+		{"@weekly", "@weekly", from, []time.Time{
+			time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC),
+		}},
+		{"@daily", "@daily", from, []time.Time{
+			time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC),
+		}},
+		{"@midnight", "@midnight", from, []time.Time{
+			time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC),
+		}},
+		{"@hourly", "@hourly", from, []time.Time{
+			time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC),
+			time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC),
+		}},
+		{"leap day", "0 0 29 2 *", from, []time.Time{
+			time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		}},
+		{"never within five years", "0 0 30 2 *", from, []time.Time{
+			{},
+		}},
+	}
+	for _, test := range testSet {
+		c, err := ParseCron(test.expr)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		checkNext(t, test.name, c, test.from, test.expected)
+	}
+}
+
+// checkNext fails t unless the times returned by c.Next, from from in turn,
+// are expected.
+func checkNext(t *testing.T, name string, c *Cron, from time.Time, expected []time.Time) {
+	t.Helper()
+	for _, e := range expected {
+		next := c.Next(from)
+		if !next.Equal(e) || next.IsZero() != e.IsZero() {
+			t.Errorf("%s: Next(%s) = %s, expected %s", name, from, next, e)
+			return
+		}
+		from = next
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	pst := time.FixedZone("PST", -8*60*60)
+	pdt := time.FixedZone("PDT", -7*60*60)
+	// clocks go from 2:00 PST to 3:00 PDT on 2024-03-10 and from 2:00 PDT
+	// to 1:00 PST on 2024-11-03
+	spring := time.Date(2024, 3, 10, 0, 0, 0, 0, pst).In(loc)
+	fall := time.Date(2024, 11, 3, 0, 0, 0, 0, pdt).In(loc)
+	testSet := []struct {
+		name     string
+		expr     string
+		from     time.Time
+		expected []time.Time
+	}{
+		{"skipped time runs after the jump", "30 2 * * *", spring, []time.Time{
+			time.Date(2024, 3, 10, 3, 30, 0, 0, pdt),
+			time.Date(2024, 3, 11, 2, 30, 0, 0, pdt),
+		}},
+		{"time after the jump", "0 3 * * *", spring, []time.Time{
+			time.Date(2024, 3, 10, 3, 0, 0, 0, pdt),
+			time.Date(2024, 3, 11, 3, 0, 0, 0, pdt),
+		}},
+		{"hourly over the jump", "0 * * * *", spring, []time.Time{
+			time.Date(2024, 3, 10, 1, 0, 0, 0, pst),
+			time.Date(2024, 3, 10, 3, 0, 0, 0, pdt),
+			time.Date(2024, 3, 10, 4, 0, 0, 0, pdt),
+		}},
+		{"repeated time runs once", "30 1 * * *", fall, []time.Time{
+			time.Date(2024, 11, 3, 1, 30, 0, 0, pdt),
+			time.Date(2024, 11, 4, 1, 30, 0, 0, pst),
+		}},
+		{"daily over the repeated hour", "0 12 * * *", fall, []time.Time{
+			time.Date(2024, 11, 3, 12, 0, 0, 0, pst),
+			time.Date(2024, 11, 4, 12, 0, 0, 0, pst),
+		}},
+	}
+	for _, test := range testSet {
+		c, err := ParseCron(test.expr)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		checkNext(t, test.name, c, test.from, test.expected)
+	}
+	// from within the repeated hour, the next time is after it
+	c, _ := ParseCron("30 1 * * *")
+	from := time.Date(2024, 11, 3, 1, 10, 0, 0, pst).In(loc)
+	checkNext(t, "from the repeated hour", c, from, []time.Time{time.Date(2024, 11, 4, 1, 30, 0, 0, pst)})
+}
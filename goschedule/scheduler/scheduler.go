@@ -0,0 +1,165 @@
+// Package scheduler runs jobs at the times given by cron expressions.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kvu787/goschedule/goschedule/logging"
+)
+
+// A Scheduler runs jobs at the times given by their cron expressions. A job
+// runs at most once at a time: a run that is due while the previous one is
+// still going is skipped.
+type Scheduler struct {
+	logger *logging.Logger
+	mu     sync.Mutex
+	jobs   []*job
+}
+
+type job struct {
+	crons  []*Cron
+	run    func(ctx context.Context) error
+	status JobStatus // guarded by Scheduler.mu
+}
+
+// JobStatus is the state of a job.
+type JobStatus struct {
+	Name      string
+	Crons     []string
+	Next      time.Time // zero if the job never runs again
+	Running   bool
+	LastStart time.Time // zero if the job has not run
+	LastEnd   time.Time
+	LastError string // empty if the last run succeeded
+}
+
+// New creates a Scheduler logging to logger.
+func New(logger *logging.Logger) *Scheduler {
+	return &Scheduler{logger: logger}
+}
+
+// Add adds a job named name that calls run at the times given by any of
+// the cron expressions crons. It must be called before Run.
+func (s *Scheduler) Add(name string, crons []string, run func(ctx context.Context) error) error {
+	j := &job{run: run, status: JobStatus{Name: name, Crons: crons}}
+	for _, expr := range crons {
+		c, err := ParseCron(expr)
+		if err != nil {
+			return fmt.Errorf("job %s: %v", name, err)
+		}
+		j.crons = append(j.crons, c)
+	}
+	if len(j.crons) == 0 {
+		return fmt.Errorf("job %s: no cron expressions", name)
+	}
+	j.status.Next = j.next(time.Now())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, j)
+	return nil
+}
+
+// next returns the first time after t that any cron of j matches.
+func (j *job) next(t time.Time) time.Time {
+	var next time.Time
+	for _, c := range j.crons {
+		if n := c.Next(t); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
+
+// Run runs the jobs of s until ctx is done, then waits for runs in progress
+// to return. Jobs are passed ctx.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	s.mu.Lock()
+	jobs := append([]*job(nil), s.jobs...)
+	s.mu.Unlock()
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			s.loop(ctx, j)
+		}(j)
+	}
+	wg.Wait()
+}
+
+// loop runs j at its times until ctx is done.
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := j.next(time.Now())
+		s.mu.Lock()
+		j.status.Next = next
+		name := j.status.Name
+		s.mu.Unlock()
+		if next.IsZero() {
+			s.logger.Warn("job will not run again", "job", name)
+			return
+		}
+		s.logger.Debug("job scheduled", "job", name, "next", next.Format(time.RFC3339))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.runJob(ctx, j)
+	}
+}
+
+// runJob runs j once and records the outcome in its status.
+func (s *Scheduler) runJob(ctx context.Context, j *job) {
+	s.mu.Lock()
+	j.status.Running = true
+	j.status.LastStart = time.Now()
+	name := j.status.Name
+	s.mu.Unlock()
+	log := s.logger.With("job", name)
+	log.Info("job started")
+	err := call(ctx, j.run)
+	s.mu.Lock()
+	j.status.Running = false
+	j.status.LastEnd = time.Now()
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+	}
+	duration := j.status.LastEnd.Sub(j.status.LastStart)
+	s.mu.Unlock()
+	if err != nil {
+		log.Error("job failed", "duration", duration, "err", err)
+		return
+	}
+	log.Info("job finished", "duration", duration)
+}
+
+// call calls run, turning a panic into an error so that a failing job does
+// not take down the process.
+func call(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run(ctx)
+}
+
+// Status returns the state of every job of s, sorted by name.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]JobStatus, len(s.jobs))
+	for i, j := range s.jobs {
+		statuses[i] = j.status
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
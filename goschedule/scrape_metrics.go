@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/kvu787/goschedule/goschedule/backend"
+	"github.com/kvu787/goschedule/goschedule/metrics"
+)
+
+var (
+	scrapeDuration     = metrics.NewGauge("goschedule_scrape_duration_seconds", "Duration of the last scrape of a schedule.", "schedule")
+	scrapeSkipped      = metrics.NewGauge("goschedule_scrape_skipped_departments", "Departments skipped by the last scrape of a schedule.", "schedule")
+	scrapeErrors       = metrics.NewGauge("goschedule_scrape_errors", "Errors in the last scrape of a schedule.", "schedule")
+	scrapeLastFinished = metrics.NewGauge("goschedule_scrape_last_finished_timestamp_seconds", "Unix time the last scrape of a schedule finished.", "schedule")
+	lastFlip           = metrics.NewGauge("goschedule_switch_last_flip_timestamp_seconds", "Unix time of the last successful switch flip of a schedule.", "schedule")
+)
+
+// observeScrape sets the scrape metrics of a schedule from a report.
+func observeScrape(schedule string, report *backend.Report) {
+	scrapeDuration.Set(report.Finished.Sub(report.Started).Seconds(), schedule)
+	scrapeSkipped.Set(float64(len(report.Skipped)), schedule)
+	scrapeErrors.Set(float64(len(report.Errors)), schedule)
+	scrapeLastFinished.Set(float64(report.Finished.Unix()), schedule)
+}
+
+// observeFlip records a successful switch flip of a schedule.
+func observeFlip(schedule string) {
+	lastFlip.Set(float64(time.Now().Unix()), schedule)
+}
+
+// serveMetrics serves the scraper metrics at /metrics on addr in the
+// background.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logger.Error("serving metrics", "addr", addr, "err", err)
+		}
+	}()
+}
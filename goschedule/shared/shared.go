@@ -1,17 +1,65 @@
 package shared
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"errors"
 )
 
-// GetSwitch queries the 'switch db' returns either 1 or 2.
-// Used to determine which database should be used to store scrape results.
-func GetSwitch(db *sql.DB) (int, error) {
-	var result int
-	query := fmt.Sprintf("SELECT switch_col FROM switch_table LIMIT 1")
-	if err := db.QueryRow(query).Scan(&result); err != nil {
+// LiveGeneration queries the 'switch db' for the generation being served:
+// the number of the application database holding the scrape it serves.
+func LiveGeneration(db *sql.DB) (int, error) {
+	if err := prepare(db); err != nil {
 		return -1, err
 	}
-	return result, nil
+	var live int
+	if err := db.QueryRow("SELECT live FROM switch_table LIMIT 1").Scan(&live); err != nil {
+		return -1, err
+	}
+	return live, nil
+}
+
+// ErrLocked is returned by LockScrape if another process is scraping the
+// schedule.
+var ErrLocked = errors.New("another scrape of the schedule is running")
+
+// scrapeLockKey is the key of the Postgres advisory lock held on the switch
+// database of a schedule while it is scraped.
+const scrapeLockKey = 787001
+
+// LockScrape takes the scrape lock of the schedule whose switch database is
+// db, so that two scrapers never write to the same schedule, and returns the
+// function releasing it. It returns ErrLocked if the lock is held elsewhere.
+// The lock is held by a database session, so it is released if the process
+// dies.
+func LockScrape(ctx context.Context, db *sql.DB) (unlock func(), err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", scrapeLockKey).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !locked {
+		conn.Close()
+		return nil, ErrLocked
+	}
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", scrapeLockKey)
+		conn.Close()
+	}, nil
+}
+
+// Scraping reports whether a process holds the scrape lock of the schedule
+// whose switch database is db.
+func Scraping(db *sql.DB) (bool, error) {
+	var held int
+	// a bigint advisory lock key is split into classid and objid
+	err := db.QueryRow(`SELECT count(*) FROM pg_locks
+		WHERE locktype = 'advisory' AND granted AND classid = 0 AND objid = $1 AND objsubid = 1
+		AND database = (SELECT oid FROM pg_database WHERE datname = current_database())`,
+		scrapeLockKey).Scan(&held)
+	return held > 0, err
 }
@@ -0,0 +1,560 @@
+package shared
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kvu787/goschedule/goschedule/backend"
+	"github.com/lib/pq"
+)
+
+// Each scrape of a schedule writes a new generation: an application
+// database numbered one more than the last, registered in the generation
+// table of the switch database. switch_table holds the number of the live
+// generation, the one being served.
+
+// SwitchSchema creates the tables of the switch database besides
+// switch_table: the generations, the report of the last scrape into each of
+// them, the pin and the audit log of the switch.
+var SwitchSchema = []string{
+	"CREATE TABLE IF NOT EXISTS generation (id integer PRIMARY KEY, created timestamp with time zone)",
+	"CREATE TABLE IF NOT EXISTS scrape_report (app integer PRIMARY KEY, finished timestamp with time zone, report text)",
+	"CREATE TABLE IF NOT EXISTS switch_pin (pinned_by text, pinned_at timestamp with time zone, reason text)",
+	"CREATE TABLE IF NOT EXISTS switch_audit (time timestamp with time zone, actor text, action text, from_app integer, to_app integer, reason text)",
+}
+
+// prepared holds the switch databases prepared by this process.
+var prepared sync.Map
+
+// prepare creates the tables of SwitchSchema in the switch database db, and
+// upgrades it if it was set up with two application databases, app1 and
+// app2, and a switch_col pointing at the one not served. It does so once
+// per process.
+func prepare(db *sql.DB) error {
+	if _, ok := prepared.Load(db); ok {
+		return nil
+	}
+	for _, statement := range SwitchSchema {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	var legacy int
+	if err := db.QueryRow(`SELECT count(*) FROM information_schema.columns
+		WHERE table_name = 'switch_table' AND column_name = 'switch_col'`).Scan(&legacy); err != nil {
+		return err
+	}
+	if legacy > 0 {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		for _, statement := range []string{
+			"ALTER TABLE switch_table ADD COLUMN live integer",
+			"UPDATE switch_table SET live = 3 - switch_col",
+			"ALTER TABLE switch_table DROP COLUMN switch_col",
+			"INSERT INTO generation (id, created) VALUES (1, now()), (2, now()) ON CONFLICT DO NOTHING",
+		} {
+			if _, err := tx.Exec(statement); err != nil {
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	prepared.Store(db, true)
+	return nil
+}
+
+// NewGeneration registers a new generation in the switch database db and
+// returns its number. The caller creates its application database.
+func NewGeneration(db *sql.DB) (int, error) {
+	if err := prepare(db); err != nil {
+		return 0, err
+	}
+	var id int
+	err := db.QueryRow("INSERT INTO generation (id, created) SELECT coalesce(max(id), 0) + 1, now() FROM generation RETURNING id").Scan(&id)
+	return id, err
+}
+
+// RemoveGeneration removes generation id, whose application database has
+// been dropped, from the switch database db.
+func RemoveGeneration(db *sql.DB, id int) error {
+	if err := prepare(db); err != nil {
+		return err
+	}
+	if _, err := db.Exec("DELETE FROM scrape_report WHERE app = $1", id); err != nil {
+		return err
+	}
+	_, err := db.Exec("DELETE FROM generation WHERE id = $1", id)
+	return err
+}
+
+// GenerationIDs returns the numbers of the generations registered in the
+// switch database db, in increasing order.
+func GenerationIDs(db *sql.DB) ([]int, error) {
+	if err := prepare(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT id FROM generation ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SaveReport stores in the switch database db the report of a scrape into
+// generation appNum, replacing the previous one.
+func SaveReport(db *sql.DB, appNum int, report *backend.Report) error {
+	encoded, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	if err := prepare(db); err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO scrape_report (app, finished, report) VALUES ($1, $2, $3)
+		ON CONFLICT (app) DO UPDATE SET finished = EXCLUDED.finished, report = EXCLUDED.report`,
+		appNum, report.Finished, string(encoded))
+	return err
+}
+
+// LoadReports returns the reports stored in the switch database db, by
+// generation.
+func LoadReports(db *sql.DB) (map[int]*backend.Report, error) {
+	if err := prepare(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT app, report FROM scrape_report")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reports := make(map[int]*backend.Report)
+	for rows.Next() {
+		var appNum int
+		var encoded string
+		if err := rows.Scan(&appNum, &encoded); err != nil {
+			return nil, err
+		}
+		report := new(backend.Report)
+		if err := json.Unmarshal([]byte(encoded), report); err != nil {
+			return nil, err
+		}
+		reports[appNum] = report
+	}
+	return reports, rows.Err()
+}
+
+// Complete reports whether the application database db holds a complete
+// scrape.
+func Complete(db *sql.DB) (bool, error) {
+	var done int
+	err := db.QueryRow("SELECT count(*) FROM checkpoint WHERE key = $1", backend.DoneKey).Scan(&done)
+	return done > 0, err
+}
+
+// A Pin keeps scrapes and imports of a schedule from flipping its switch.
+type Pin struct {
+	By     string
+	At     time.Time
+	Reason string
+}
+
+// An AuditEntry records a change to the switch of a schedule.
+type AuditEntry struct {
+	Time   time.Time
+	Actor  string // who made the change, ex. a user name or "scraper"
+	Action string // scrape, import, flip, rollback, pin or unpin
+	From   int    // the live generation before the change
+	To     int    // the live generation after the change; From for pin and unpin
+	Reason string
+}
+
+// Errors of changes to the switch of a schedule.
+var (
+	ErrPinned       = errors.New("the switch is pinned")
+	ErrNotPinned    = errors.New("the switch is not pinned")
+	ErrNoGeneration = errors.New("no such generation")
+	ErrLive         = errors.New("the generation is live")
+	ErrIncomplete   = errors.New("the generation does not hold a complete scrape")
+	ErrNoNewer      = errors.New("no complete generation is newer than the live one")
+	ErrNoOlder      = errors.New("no complete generation is older than the live one")
+)
+
+// FlipSwitch serves generation appNum, just scraped into, and records the
+// change in the audit log as action by actor. It returns ErrPinned if the
+// switch is pinned.
+func FlipSwitch(db *sql.DB, appNum int, actor, action, reason string) error {
+	return flip(db, appNum, AuditEntry{Actor: actor, Action: action, Reason: reason}, true)
+}
+
+// flip serves generation appNum of the schedule whose switch database is db
+// and records entry, with its time and generations set, in the audit log.
+// With honorPin, it returns ErrPinned if the switch is pinned.
+func flip(db *sql.DB, appNum int, entry AuditEntry, honorPin bool) error {
+	if err := prepare(db); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// locking the switch row orders flips and pins
+	var live int
+	if err := tx.QueryRow("SELECT live FROM switch_table LIMIT 1 FOR UPDATE").Scan(&live); err != nil {
+		return err
+	}
+	if honorPin {
+		var pins int
+		if err := tx.QueryRow("SELECT count(*) FROM switch_pin").Scan(&pins); err != nil {
+			return err
+		}
+		if pins > 0 {
+			return ErrPinned
+		}
+	}
+	if _, err := tx.Exec("UPDATE switch_table SET live = $1", appNum); err != nil {
+		return err
+	}
+	entry.From, entry.To = live, appNum
+	if err := audit(tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// audit records entry, at the current time, in the audit log.
+func audit(tx *sql.Tx, entry AuditEntry) error {
+	_, err := tx.Exec("INSERT INTO switch_audit (time, actor, action, from_app, to_app, reason) VALUES ($1, $2, $3, $4, $5, $6)",
+		time.Now(), entry.Actor, entry.Action, entry.From, entry.To, entry.Reason)
+	return err
+}
+
+// PinSwitch pins the switch of the schedule whose switch database is db, so
+// that scrapes and imports do not flip it until UnpinSwitch is called. It
+// returns ErrPinned if it is already pinned.
+func PinSwitch(db *sql.DB, actor, reason string) error {
+	return setPin(db, AuditEntry{Actor: actor, Action: "pin", Reason: reason})
+}
+
+// UnpinSwitch unpins the switch of the schedule whose switch database is
+// db. It returns ErrNotPinned if it is not pinned.
+func UnpinSwitch(db *sql.DB, actor, reason string) error {
+	return setPin(db, AuditEntry{Actor: actor, Action: "unpin", Reason: reason})
+}
+
+// setPin pins or unpins a switch as given by entry.Action and records entry
+// in the audit log.
+func setPin(db *sql.DB, entry AuditEntry) error {
+	if err := prepare(db); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var live, pins int
+	if err := tx.QueryRow("SELECT live FROM switch_table LIMIT 1 FOR UPDATE").Scan(&live); err != nil {
+		return err
+	}
+	if err := tx.QueryRow("SELECT count(*) FROM switch_pin").Scan(&pins); err != nil {
+		return err
+	}
+	switch {
+	case entry.Action == "pin" && pins > 0:
+		return ErrPinned
+	case entry.Action == "pin":
+		_, err = tx.Exec("INSERT INTO switch_pin (pinned_by, pinned_at, reason) VALUES ($1, $2, $3)", entry.Actor, time.Now(), entry.Reason)
+	case pins == 0:
+		return ErrNotPinned
+	default:
+		_, err = tx.Exec("DELETE FROM switch_pin")
+	}
+	if err != nil {
+		return err
+	}
+	entry.From, entry.To = live, live
+	if err := audit(tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPin returns the pin of the switch of the schedule whose switch
+// database is db, or nil if it is not pinned.
+func GetPin(db *sql.DB) (*Pin, error) {
+	if err := prepare(db); err != nil {
+		return nil, err
+	}
+	pin := new(Pin)
+	err := db.QueryRow("SELECT pinned_by, pinned_at, reason FROM switch_pin LIMIT 1").Scan(&pin.By, &pin.At, &pin.Reason)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pin, nil
+}
+
+// AuditLog returns the last n entries of the audit log of the switch of the
+// schedule whose switch database is db, most recent first.
+func AuditLog(db *sql.DB, n int) ([]AuditEntry, error) {
+	if err := prepare(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT time, actor, action, from_app, to_app, reason FROM switch_audit ORDER BY time DESC LIMIT $1", n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.Time, &e.Actor, &e.Action, &e.From, &e.To, &e.Reason); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// String formats e as a line of the audit log.
+func (e AuditEntry) String() string {
+	change := fmt.Sprintf("app %d to app %d", e.From, e.To)
+	if e.From == e.To {
+		change = fmt.Sprintf("app %d live", e.From)
+	}
+	s := fmt.Sprintf("%s %s %s (%s)", e.Time.Format(time.RFC3339), e.Actor, e.Action, change)
+	if e.Reason != "" {
+		s += ": " + e.Reason
+	}
+	return s
+}
+
+// A Generation is an application database of a schedule.
+type Generation struct {
+	ID       int
+	Created  time.Time
+	Complete bool
+	Err      error           // set if whether it is complete is unknown
+	Report   *backend.Report // nil if there is none
+}
+
+// A SwitchState describes the generations of a schedule.
+type SwitchState struct {
+	Live        int          // the generation being served
+	Generations []Generation // in increasing order
+	Pin         *Pin         // nil if the switch is not pinned
+}
+
+// State returns the state of the generations of the schedule whose switch
+// database is db. openApp connects to the application database of a
+// generation.
+func State(db *sql.DB, openApp func(appNum int) (*sql.DB, error)) (SwitchState, error) {
+	var s SwitchState
+	var err error
+	if s.Live, err = LiveGeneration(db); err != nil {
+		return s, err
+	}
+	reports, err := LoadReports(db)
+	if err != nil {
+		return s, err
+	}
+	if s.Pin, err = GetPin(db); err != nil {
+		return s, err
+	}
+	rows, err := db.Query("SELECT id, created FROM generation ORDER BY id")
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var g Generation
+		if err := rows.Scan(&g.ID, &g.Created); err != nil {
+			return s, err
+		}
+		g.Report = reports[g.ID]
+		s.Generations = append(s.Generations, g)
+	}
+	if err := rows.Err(); err != nil {
+		return s, err
+	}
+	for i := range s.Generations {
+		app, err := openApp(s.Generations[i].ID)
+		if err != nil {
+			return s, err
+		}
+		complete, err := Complete(app)
+		app.Close()
+		// a generation being created or dropped may have no database or
+		// tables yet, so it holds no scrape
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && (pqErr.Code.Name() == "invalid_catalog_name" || pqErr.Code.Name() == "undefined_table") {
+			err = nil
+		}
+		s.Generations[i].Complete, s.Generations[i].Err = complete, err
+	}
+	return s, nil
+}
+
+// Err returns an error if whether a generation of s is complete is unknown,
+// as when its database cannot be reached.
+func (s SwitchState) Err() error {
+	for _, g := range s.Generations {
+		if g.Err != nil {
+			return fmt.Errorf("generation %d: %v", g.ID, g.Err)
+		}
+	}
+	return nil
+}
+
+// Generation returns generation id of s.
+func (s SwitchState) Generation(id int) (Generation, bool) {
+	for _, g := range s.Generations {
+		if g.ID == id {
+			return g, true
+		}
+	}
+	return Generation{}, false
+}
+
+// Next returns the newest complete generation newer than the live one,
+// served by Flip.
+func (s SwitchState) Next() (int, error) {
+	for i := len(s.Generations) - 1; i >= 0 && s.Generations[i].ID > s.Live; i-- {
+		if s.Generations[i].Complete {
+			return s.Generations[i].ID, nil
+		}
+	}
+	return 0, ErrNoNewer
+}
+
+// Previous returns the newest complete generation older than the live one,
+// served by Rollback.
+func (s SwitchState) Previous() (int, error) {
+	for i := len(s.Generations) - 1; i >= 0; i-- {
+		if g := s.Generations[i]; g.ID < s.Live && g.Complete {
+			return g.ID, nil
+		}
+	}
+	return 0, ErrNoOlder
+}
+
+// Newest returns the newest generation, or the zero Generation if there is
+// none.
+func (s SwitchState) Newest() Generation {
+	if len(s.Generations) == 0 {
+		return Generation{}
+	}
+	return s.Generations[len(s.Generations)-1]
+}
+
+// Expired returns the generations to drop to retain keep generations: the
+// live one, the keep newest complete ones, and the newest one if it is an
+// incomplete scrape newer than the live one, which may be resumed.
+// Generations whose completeness is unknown are never dropped.
+func (s SwitchState) Expired(keep int) []int {
+	var expired []int
+	kept := 0
+	newest := s.Newest()
+	for i := len(s.Generations) - 1; i >= 0; i-- {
+		g := s.Generations[i]
+		switch {
+		case g.ID == s.Live:
+			kept++
+		case g.Err != nil:
+			// may be a complete generation that cannot be reached
+		case g.Complete && kept < keep:
+			kept++
+		case g.ID == newest.ID && !g.Complete && g.ID > s.Live:
+			// an interrupted scrape, kept to be resumed
+		default:
+			expired = append(expired, g.ID)
+		}
+	}
+	return expired
+}
+
+// Flip serves the newest complete generation newer than the live one, such
+// as after a rollback or a scrape while the switch was pinned, and records
+// the change in the audit log. A pin does not stop it. It returns ErrLocked
+// if the schedule is being scraped.
+func Flip(ctx context.Context, db *sql.DB, openApp func(appNum int) (*sql.DB, error), actor, reason string) error {
+	return serveGeneration(ctx, db, openApp, SwitchState.Next, AuditEntry{Actor: actor, Action: "flip", Reason: reason})
+}
+
+// Rollback serves the newest complete generation older than the live one,
+// the one served before the last flip if it is retained, and records the
+// change in the audit log. A pin does not stop it. It returns ErrLocked if
+// the schedule is being scraped.
+func Rollback(ctx context.Context, db *sql.DB, openApp func(appNum int) (*sql.DB, error), actor, reason string) error {
+	return serveGeneration(ctx, db, openApp, SwitchState.Previous, AuditEntry{Actor: actor, Action: "rollback", Reason: reason})
+}
+
+// Serve serves generation appNum, if it holds a complete scrape, and records
+// the change in the audit log as a flip or rollback. A pin does not stop
+// it. It returns ErrLocked if the schedule is being scraped.
+func Serve(ctx context.Context, db *sql.DB, openApp func(appNum int) (*sql.DB, error), appNum int, actor, reason string) error {
+	target := func(s SwitchState) (int, error) {
+		g, ok := s.Generation(appNum)
+		switch {
+		case !ok:
+			return 0, ErrNoGeneration
+		case g.ID == s.Live:
+			return 0, ErrLive
+		case g.Err != nil:
+			return 0, g.Err
+		case !g.Complete:
+			return 0, ErrIncomplete
+		}
+		return g.ID, nil
+	}
+	return serveGeneration(ctx, db, openApp, target, AuditEntry{Actor: actor, Reason: reason})
+}
+
+// serveGeneration serves the generation chosen by target, holding the
+// scrape lock so that it is not being scraped into or dropped. An entry
+// without an action is recorded as a flip or a rollback by the generation
+// served.
+func serveGeneration(ctx context.Context, db *sql.DB, openApp func(appNum int) (*sql.DB, error), target func(SwitchState) (int, error), entry AuditEntry) error {
+	unlock, err := LockScrape(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	state, err := State(db, openApp)
+	if err != nil {
+		return err
+	}
+	appNum, err := target(state)
+	if err != nil {
+		return err
+	}
+	if entry.Action == "" {
+		entry.Action = "flip"
+		if appNum < state.Live {
+			entry.Action = "rollback"
+		}
+	}
+	return flip(db, appNum, entry, false)
+}
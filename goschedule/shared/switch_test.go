@@ -0,0 +1,122 @@
+package shared
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// generations returns generations 1 to n, complete but for those in
+// incomplete and those whose completeness is unknown.
+func generations(n int, incomplete, unknown []int) []Generation {
+	var gs []Generation
+	for id := 1; id <= n; id++ {
+		gs = append(gs, Generation{ID: id, Complete: true})
+	}
+	for _, id := range incomplete {
+		gs[id-1].Complete = false
+	}
+	for _, id := range unknown {
+		gs[id-1].Complete = false
+		gs[id-1].Err = errors.New("connection refused")
+	}
+	return gs
+}
+
+func TestSwitchState(t *testing.T) {
+	testSet := []struct {
+		name     string
+		state    SwitchState
+		keep     int
+		next     int // 0 for ErrNoNewer
+		previous int // 0 for ErrNoOlder
+		expired  []int
+	}{
+		{
+			name:     "live at newest",
+			state:    SwitchState{Live: 4, Generations: generations(4, nil, nil)},
+			keep:     2,
+			previous: 3,
+			expired:  []int{2, 1},
+		},
+		{
+			name:     "live rolled back",
+			state:    SwitchState{Live: 3, Generations: generations(4, nil, nil)},
+			keep:     3,
+			next:     4,
+			previous: 2,
+			expired:  []int{1},
+		},
+		{
+			name:     "pinned with newer complete generations",
+			state:    SwitchState{Live: 2, Generations: generations(4, nil, nil), Pin: &Pin{By: "admin"}},
+			keep:     2,
+			next:     4,
+			previous: 1,
+			expired:  []int{1},
+		},
+		{
+			name:     "interrupted newest scrape",
+			state:    SwitchState{Live: 3, Generations: generations(4, []int{4}, nil)},
+			keep:     2,
+			previous: 2,
+			expired:  []int{1},
+		},
+		{
+			name:     "incomplete older scrape",
+			state:    SwitchState{Live: 4, Generations: generations(4, []int{2}, nil)},
+			keep:     3,
+			previous: 3,
+			expired:  []int{2},
+		},
+		{
+			name:     "keep 1",
+			state:    SwitchState{Live: 3, Generations: generations(3, nil, nil)},
+			keep:     1,
+			previous: 2,
+			expired:  []int{2, 1},
+		},
+		{
+			name:     "keep 1 rolled back",
+			state:    SwitchState{Live: 2, Generations: generations(3, nil, nil)},
+			keep:     1,
+			next:     3,
+			previous: 1,
+			expired:  []int{1},
+		},
+		{
+			name:     "unknown completeness",
+			state:    SwitchState{Live: 4, Generations: generations(4, nil, []int{1, 3})},
+			keep:     1,
+			previous: 2,
+			expired:  []int{2},
+		},
+		{
+			name:  "no generations",
+			state: SwitchState{},
+			keep:  2,
+		},
+	}
+	for _, test := range testSet {
+		next, err := test.state.Next()
+		if next != test.next || (err == nil) != (test.next != 0) || (err != nil && err != ErrNoNewer) {
+			t.Errorf("%s: Next() = %d, %v, expected %d", test.name, next, err, test.next)
+		}
+		previous, err := test.state.Previous()
+		if previous != test.previous || (err == nil) != (test.previous != 0) || (err != nil && err != ErrNoOlder) {
+			t.Errorf("%s: Previous() = %d, %v, expected %d", test.name, previous, err, test.previous)
+		}
+		if expired := test.state.Expired(test.keep); !reflect.DeepEqual(expired, test.expired) {
+			t.Errorf("%s: Expired(%d) = %v, expected %v", test.name, test.keep, expired, test.expired)
+		}
+	}
+}
+
+func TestSwitchStateErr(t *testing.T) {
+	if err := (SwitchState{Live: 2, Generations: generations(2, []int{1}, nil)}).Err(); err != nil {
+		t.Errorf("got %v, expected nil", err)
+	}
+	if err := (SwitchState{Live: 2, Generations: generations(2, nil, []int{1})}).Err(); err == nil {
+		t.Errorf("got nil, expected an error for generation 1")
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/kvu787/goschedule/goschedule/backend"
+	"github.com/kvu787/goschedule/goschedule/logging"
+	"github.com/kvu787/goschedule/goschedule/shared"
+)
+
+var switchCommand = &command{
+	name:  "switch",
+	args:  "<status|flip|rollback|serve|pin|unpin|gc>",
+	short: "Show or change which generation of a schedule is served.",
+	long: `
+Each scrape or import of a schedule writes a new generation: an application
+database numbered one more than the last. Once it finishes, the switch is
+flipped to serve it. The generation served, the --generations newest
+complete ones (see 'goschedule help config') and an interrupted scrape are
+retained; older ones are dropped after each scrape and by gc.
+
+	status     prints the live generation, every retained generation with
+	           the last scrape into it, the pin and the last changes to the
+	           switch
+	flip       serves the newest complete generation newer than the live
+	           one, ex. after a rollback or a scrape while pinned
+	rollback   serves the newest complete generation older than the live
+	           one: the one served before the last flip, if a bad scrape
+	           was published
+	serve      serves complete generation --generation
+	pin        keeps scrapes and imports from flipping the switch, until
+	           unpin; flip, rollback and serve still work
+	unpin      lets scrapes and imports flip the switch again
+	gc         drops the generations no longer retained
+
+flip, rollback, serve and gc fail while the schedule is being scraped. Every
+change of the switch is recorded in its audit log with the user running
+goschedule, the time and --reason.
+
+Examples:
+
+	'goschedule switch rollback --config=./config.json --schedule=aut2013 --reason="sections missing"'
+	'goschedule switch serve --config=./config.json --schedule=aut2013 --generation=12'
+	'goschedule switch pin --config=./config.json --schedule=aut2013 --reason="investigating"'`,
+	flags: func(fs *flag.FlagSet) func(config, []string) error {
+		var f switchFlags
+		fs.StringVar(&f.schedule, "schedule", "", "Schedule `name` (from config).")
+		fs.StringVar(&f.reason, "reason", "", "Why the switch is changed, as `text` recorded in its audit log.")
+		fs.IntVar(&f.generation, "generation", 0, "Generation `number` to serve with serve.")
+		fs.IntVar(&f.entries, "entries", 10, "Number of audit log `entries` printed by status.")
+		return func(conf config, args []string) error {
+			return handleSwitch(conf, args, f)
+		}
+	},
+}
+
+// switchFlags are the flags of the switch command.
+type switchFlags struct {
+	schedule   string
+	reason     string
+	generation int
+	entries    int
+}
+
+func handleSwitch(conf config, args []string, f switchFlags) error {
+	if len(args) != 1 {
+		return usagef("expected one argument, status, flip, rollback, serve, pin, unpin or gc")
+	}
+	if _, ok := conf.schedule(f.schedule); !ok {
+		return usagef("cannot find schedule %q in config", f.schedule)
+	}
+	if args[0] == "serve" && f.generation == 0 {
+		return usagef("missing --generation flag")
+	}
+	switchDb, err := openSwitchDb(conf, f.schedule)
+	if err != nil {
+		return fmt.Errorf("connecting to switch database: %v", err)
+	}
+	defer switchDb.Close()
+	openApp := appDbOpener(conf, f.schedule)
+	actor := currentUser()
+	log := logger.With("schedule", f.schedule, "actor", actor)
+	switch args[0] {
+	case "status":
+		return printSwitchStatus(switchDb, openApp, f)
+	case "flip":
+		err = shared.Flip(context.Background(), switchDb, openApp, actor, f.reason)
+	case "rollback":
+		err = shared.Rollback(context.Background(), switchDb, openApp, actor, f.reason)
+	case "serve":
+		err = shared.Serve(context.Background(), switchDb, openApp, f.generation, actor, f.reason)
+	case "pin":
+		err = shared.PinSwitch(switchDb, actor, f.reason)
+	case "unpin":
+		err = shared.UnpinSwitch(switchDb, actor, f.reason)
+	case "gc":
+		return collectSchedule(conf, f.schedule, log)
+	default:
+		return usagef("unknown argument %q, expected status, flip, rollback, serve, pin, unpin or gc", args[0])
+	}
+	if err != nil {
+		return err
+	}
+	if args[0] == "flip" || args[0] == "rollback" || args[0] == "serve" {
+		observeFlip(f.schedule)
+	}
+	live, err := shared.LiveGeneration(switchDb)
+	if err != nil {
+		return fmt.Errorf("reading switch: %v", err)
+	}
+	log.Info("switch changed", "action", args[0], "live", live, "reason", f.reason)
+	return nil
+}
+
+// collectSchedule drops the generations of a schedule no longer retained,
+// holding its scrape lock.
+func collectSchedule(conf config, schedule string, log *logging.Logger) error {
+	switchDb, unlock, err := lockSchedule(context.Background(), conf, schedule)
+	if err != nil {
+		return err
+	}
+	defer switchDb.Close()
+	defer unlock()
+	return collectGenerations(conf, schedule, switchDb, log)
+}
+
+// printSwitchStatus prints the generations, pin and audit log of the switch
+// of a schedule.
+func printSwitchStatus(switchDb *sql.DB, openApp func(appNum int) (*sql.DB, error), f switchFlags) error {
+	state, err := shared.State(switchDb, openApp)
+	if err != nil {
+		return fmt.Errorf("reading switch: %v", err)
+	}
+	entries, err := shared.AuditLog(switchDb, f.entries)
+	if err != nil {
+		return fmt.Errorf("reading audit log: %v", err)
+	}
+	scraping, err := shared.Scraping(switchDb)
+	if err != nil {
+		return fmt.Errorf("reading scrape lock: %v", err)
+	}
+	fmt.Printf("schedule %s\n", f.schedule)
+	fmt.Printf("live:     app %d\n", state.Live)
+	if state.Pin != nil {
+		fmt.Printf("pinned:   by %s at %s", state.Pin.By, state.Pin.At.Format(time.RFC3339))
+		if state.Pin.Reason != "" {
+			fmt.Printf(": %s", state.Pin.Reason)
+		}
+		fmt.Println()
+	} else {
+		fmt.Println("pinned:   no")
+	}
+	fmt.Printf("flip:     %s\n", describeTarget(state.Next()))
+	fmt.Printf("rollback: %s\n", describeTarget(state.Previous()))
+	fmt.Println("\ngenerations:")
+	newest := state.Newest().ID
+	for _, g := range state.Generations {
+		marker := " "
+		if g.ID == state.Live {
+			marker = "*"
+		}
+		scrape := describeScrape(g.Report, g.Complete)
+		if g.Err != nil {
+			scrape = "unknown whether complete, " + g.Err.Error()
+		}
+		if scraping && g.ID == newest && !g.Complete {
+			scrape = "being scraped"
+		}
+		fmt.Printf("\t%s app %d, created %s, %s\n", marker, g.ID, g.Created.Format(time.RFC3339), scrape)
+	}
+	if len(entries) > 0 {
+		fmt.Println("\nlast changes:")
+		for _, entry := range entries {
+			fmt.Printf("\t%s\n", entry)
+		}
+	}
+	return nil
+}
+
+// describeScrape describes the scrape in an application database.
+func describeScrape(report *backend.Report, complete bool) string {
+	s := "incomplete scrape"
+	if complete {
+		s = "complete scrape"
+	}
+	if report == nil {
+		return s + ", no report"
+	}
+	return fmt.Sprintf("%s finished %s, %d classes, %d sections, %d errors",
+		s, report.Finished.Format(time.RFC3339), report.Classes, report.Sects, len(report.Errors))
+}
+
+// describeTarget describes the result of SwitchState.Next or Previous.
+func describeTarget(appNum int, err error) string {
+	if err != nil {
+		return "not possible, " + err.Error()
+	}
+	return fmt.Sprintf("serves app %d", appNum)
+}
+
+// currentUser returns the name of the user running goschedule, recorded in
+// the audit log of switches.
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
@@ -0,0 +1,214 @@
+package goschedule
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// A CatalogEntry is a class description from the UW course catalog, parsed
+// into its structured parts.
+type CatalogEntry struct {
+	AbbreviationCode string
+	Title            string
+	Credits          Credits
+	Areas            []string
+	Prerequisites    *Prereq
+	Offered          []string
+	Joint            []string
+	Description      string // raw HTML, as stored in Class.Description
+}
+
+// Credits is the credit value of a class as listed in the course catalog,
+// for example "(4)", "(1-5, max. 15)" or "(*, max. 10)".
+type Credits struct {
+	Raw      string
+	Min      int64
+	Max      int64
+	MaxTotal int64 // maximum credits if the class can be repeated, else 0
+	Variable bool  // true for "*", credits arranged with the instructor
+}
+
+// areasOfKnowledge are the general education tags that can follow the credits
+// in a catalog entry header.
+var areasOfKnowledge = []string{"I&S", "VLPA", "NW", "QSR", "DIV", "C", "W"}
+
+// quarterNames maps the quarter abbreviations used after "Offered:" to names.
+var quarterNames = map[string]string{
+	"A":  "Autumn",
+	"W":  "Winter",
+	"Sp": "Spring",
+	"S":  "Summer",
+}
+
+// ExtractCatalogEntries extracts class descriptions from content (a course
+// catalog page for a department) and parses each into a CatalogEntry.
+// An entry is a paragraph starting with a bold, named anchor; its description
+// runs from the end of the bold header to the next blank line.
+func ExtractCatalogEntries(content string) ([]CatalogEntry, error) {
+	var entries []CatalogEntry
+	tokens := tokenize(content)
+	for i := 0; i < len(tokens); i++ {
+		name, anchor, ok := catalogEntryAnchor(tokens, i)
+		if !ok {
+			continue
+		}
+		header, j := textUntil(tokens, anchor+1, "b")
+		if j == len(tokens) {
+			continue
+		}
+		end, k := len(content), j+1
+		for ; k < len(tokens); k++ {
+			if _, _, ok := catalogEntryAnchor(tokens, k); ok {
+				end = tokens[k].Start
+				break
+			}
+			if tokens[k].Type != html.TextToken {
+				continue
+			}
+			if n := strings.Index(content[tokens[k].Start:tokens[k].End], "\n\n"); n >= 0 {
+				end = tokens[k].Start + n
+				break
+			}
+		}
+		entry := ParseCatalogEntry(strings.TrimSpace(header), strings.TrimSpace(content[tokens[j].End:end]))
+		entry.AbbreviationCode = strings.ToLower(strings.TrimSpace(name))
+		entries = append(entries, entry)
+		i = k - 1
+	}
+	return entries, nil
+}
+
+// catalogEntryAnchor reports whether tokens[i] starts a catalog entry, a <p>
+// followed by <b> and a named anchor. It returns the name and the index of
+// the anchor.
+func catalogEntryAnchor(tokens []token, i int) (string, int, bool) {
+	if !tokens[i].isStart("p") {
+		return "", 0, false
+	}
+	i = skipSpace(tokens, i+1)
+	if i == len(tokens) || !tokens[i].isStart("b") {
+		return "", 0, false
+	}
+	i = skipSpace(tokens, i+1)
+	if i == len(tokens) || !tokens[i].isStart("a") {
+		return "", 0, false
+	}
+	name, ok := tokens[i].attr("name")
+	return name, i, ok
+}
+
+// ParseCatalogEntry parses the header of a catalog entry (ex. "CSE 142 Computer
+// Programming I (4) NW, QSR") and its HTML description body.
+// The returned CatalogEntry has an empty AbbreviationCode.
+func ParseCatalogEntry(header, description string) CatalogEntry {
+	var entry CatalogEntry
+	entry.Description = description
+	entry.Title = header
+	if m := catalogCreditsRe.FindStringSubmatchIndex(header); m != nil {
+		entry.Title = strings.TrimSpace(header[:m[0]])
+		entry.Credits = ParseCredits(header[m[2]:m[3]])
+		entry.Areas = parseAreas(header[m[4]:m[5]])
+	}
+	text := strings.Join(strings.Fields(htmlText(description, " ")), " ")
+	if m := catalogPrereqRe.FindStringSubmatch(text); m != nil {
+		entry.Prerequisites = ParsePrereq(m[1])
+	}
+	if m := catalogOfferedRe.FindStringSubmatch(text); m != nil {
+		entry.Offered, entry.Joint = parseOffered(m[1])
+	}
+	return entry
+}
+
+// ParseCredits parses the text inside the parentheses of a catalog entry
+// header into Credits.
+func ParseCredits(s string) Credits {
+	credits := Credits{Raw: strings.TrimSpace(s)}
+	parts := strings.Split(credits.Raw, ",")
+	value := strings.TrimSpace(parts[0])
+	switch {
+	case strings.HasPrefix(value, "*"):
+		credits.Variable = true
+	case strings.ContainsAny(value, "-/"):
+		bounds := strings.FieldsFunc(value, func(r rune) bool { return r == '-' || r == '/' })
+		if len(bounds) > 0 {
+			credits.Min, _ = strconv.ParseInt(strings.TrimSpace(bounds[0]), 10, 64)
+			credits.Max = credits.Min
+		}
+		if len(bounds) > 1 {
+			credits.Max, _ = strconv.ParseInt(strings.TrimSpace(bounds[len(bounds)-1]), 10, 64)
+		}
+	default:
+		credits.Min, _ = strconv.ParseInt(value, 10, 64)
+		credits.Max = credits.Min
+	}
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(strings.ToLower(part), "max") {
+			if m := spotsRe.FindString(part); m != "" {
+				credits.MaxTotal, _ = strconv.ParseInt(m, 10, 64)
+			}
+		}
+	}
+	return credits
+}
+
+// parseAreas returns the areas of knowledge found in s, in the order of
+// areasOfKnowledge.
+func parseAreas(s string) []string {
+	tokens := make(map[string]bool)
+	for _, token := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '/' || r == ' '
+	}) {
+		tokens[strings.ToUpper(token)] = true
+	}
+	var areas []string
+	for _, area := range areasOfKnowledge {
+		if tokens[area] {
+			areas = append(areas, area)
+		}
+	}
+	return areas
+}
+
+// parseOffered parses the text after "Offered:" into quarter abbreviations
+// and the abbreviation codes of jointly offered classes.
+func parseOffered(s string) (quarters, joint []string) {
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(strings.ToLower(part), "jointly with") {
+			var dept string
+			joint = append(joint, scanCourseRefs(part, &dept)...)
+			continue
+		}
+		if catalogQuartersRe.MatchString(part) {
+			quarters = append(quarters, splitQuarters(part)...)
+		}
+	}
+	return quarters, joint
+}
+
+// splitQuarters splits a string such as "AWSpS" into quarter abbreviations.
+func splitQuarters(s string) []string {
+	var quarters []string
+	for len(s) > 0 {
+		if strings.HasPrefix(s, "Sp") {
+			quarters = append(quarters, "Sp")
+			s = s[2:]
+		} else {
+			quarters = append(quarters, s[:1])
+			s = s[1:]
+		}
+	}
+	return quarters
+}
+
+// QuarterName returns the full name of a quarter abbreviation used in
+// CatalogEntry.Offered, or the abbreviation itself if it is unknown.
+func QuarterName(abbreviation string) string {
+	if name, ok := quarterNames[abbreviation]; ok {
+		return name
+	}
+	return abbreviation
+}
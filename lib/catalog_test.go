@@ -0,0 +1,90 @@
+package goschedule
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractCatalogEntries(t *testing.T) {
+	content := `<P><B><A NAME="cse142">CSE 142 Computer Programming I (4) NW, QSR</A></B><BR>Basic programming-in-the-small abilities and concepts. Offered: AWSpS.<br><a href="/x">View course details in MyPlan: CSE 142</a></P>
+
+<P><B><A NAME="cse143">CSE 143 Computer Programming II (5) NW, QSR</A></B><BR>Continuation of CSE 142. Prerequisite: CSE 142. Offered: AWSpS.</P>
+
+`
+	entries, err := ExtractCatalogEntries(content)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("case: %q: got %d entries, error %v", content, len(entries), err)
+	}
+	entry := entries[1]
+	if entry.AbbreviationCode != "cse143" || entry.Title != "CSE 143 Computer Programming II" {
+		t.Errorf("got %q %q", entry.AbbreviationCode, entry.Title)
+	}
+	if entry.Credits.Min != 5 || entry.Credits.Max != 5 {
+		t.Errorf("got credits %+v", entry.Credits)
+	}
+	if !reflect.DeepEqual(entry.Areas, []string{"NW", "QSR"}) {
+		t.Errorf("got areas %v", entry.Areas)
+	}
+	if !reflect.DeepEqual(entry.Offered, []string{"A", "W", "Sp", "S"}) {
+		t.Errorf("got offered %v", entry.Offered)
+	}
+	if entry.Prerequisites.String() != "cse142" {
+		t.Errorf("got prerequisites %q", entry.Prerequisites)
+	}
+	descriptions, err := ExtractClassDescriptions(content)
+	if err != nil || descriptions["cse142"] != entries[0].Description {
+		t.Errorf("got descriptions %q, error %v", descriptions, err)
+	}
+}
+
+func TestParseCredits(t *testing.T) {
+	testSet := []struct {
+		in       string
+		expected Credits
+	}{
+		{`4`, Credits{Raw: "4", Min: 4, Max: 4}},
+		{`1-5, max. 15`, Credits{Raw: "1-5, max. 15", Min: 1, Max: 5, MaxTotal: 15}},
+		{`3/5`, Credits{Raw: "3/5", Min: 3, Max: 5}},
+		{`*, max. 10`, Credits{Raw: "*, max. 10", MaxTotal: 10, Variable: true}},
+	}
+	for _, test := range testSet {
+		if credits := ParseCredits(test.in); credits != test.expected {
+			t.Errorf("case %q: got %+v", test.in, credits)
+		}
+	}
+}
+
+func TestParseOffered(t *testing.T) {
+	quarters, joint := parseOffered("jointly with B H 515, ESS 301; W")
+	if !reflect.DeepEqual(quarters, []string{"W"}) || !reflect.DeepEqual(joint, []string{"bh515", "ess301"}) {
+		t.Errorf("got %v %v", quarters, joint)
+	}
+}
+
+func TestParsePrereq(t *testing.T) {
+	testSet := []struct {
+		in       string
+		expected string
+	}{
+		{``, ``},
+		{`CSE 143`, `cse143`},
+		{`MATH 124 or 125`, `math124 or math125`},
+		{`either MATH 124, MATH 134, or MATH 145`, `math124 or math134 or math145`},
+		{`CSE 143; either MATH 126 or MATH 136`, `cse143 and (math126 or math136)`},
+		{`minimum grade of 2.0 in CSE 143 and A A 210`, `cse143 and aa210`},
+		{`permission of instructor`, `permission of instructor`},
+	}
+	for _, test := range testSet {
+		if prereq := ParsePrereq(test.in).String(); prereq != test.expected {
+			t.Errorf("case %q: got %q", test.in, prereq)
+		}
+	}
+}
+
+func FuzzExtractCatalogEntries(f *testing.F) {
+	addPages(f, "crscat_*.html")
+	f.Add("<p><b><a name=x>X 1 (*, max. 5) NW</a></b>Prerequisite: either X 1 or (Y 2 and Z 3). Offered: jointly with W 4; AWSp.\n\n")
+	f.Fuzz(func(t *testing.T, content string) {
+		ExtractCatalogEntries(content)
+	})
+}
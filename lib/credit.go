@@ -0,0 +1,93 @@
+package goschedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SectCredit is the credit value of a section, parsed from Sect.Credit.
+type SectCredit struct {
+	Min, Max int64
+	// Variable is set for "VAR" and for ranges such as "2-5", where the
+	// credits are chosen by the student.
+	Variable bool
+	// Quiz is set for quiz sections ("QZ"), which give no credit of their own.
+	Quiz bool
+}
+
+// ParseSectCredit parses the Credit column of a section line, ex. "5",
+// "2-5", "VAR" or "QZ". Other section types (ex. "LB") have no credits.
+func ParseSectCredit(s string) SectCredit {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	var credit SectCredit
+	switch {
+	case s == "VAR":
+		credit.Variable = true
+	case s == "QZ":
+		credit.Quiz = true
+	case strings.Contains(s, "-"):
+		bounds := strings.SplitN(s, "-", 2)
+		min, err := strconv.ParseInt(strings.TrimSpace(bounds[0]), 10, 64)
+		if err != nil {
+			return credit
+		}
+		max, err := strconv.ParseInt(strings.TrimSpace(bounds[1]), 10, 64)
+		if err != nil || max < min {
+			max = min
+		}
+		credit.Min, credit.Max = min, max
+		credit.Variable = max > min
+	default:
+		credit.Min, _ = strconv.ParseInt(s, 10, 64)
+		credit.Max = credit.Min
+	}
+	return credit
+}
+
+// ParseFee parses the Fee column of a section line, ex. "$50" or "$1,250.50",
+// into cents. ok is false if s is not a fee.
+func ParseFee(s string) (cents int64, ok bool) {
+	s = strings.Replace(strings.TrimPrefix(strings.TrimSpace(s), "$"), ",", "", -1)
+	if s == "" {
+		return 0, false
+	}
+	dollars, fraction := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		dollars, fraction = s[:i], s[i+1:]
+	}
+	if !isDigits(dollars) || len(fraction) > 2 || (fraction != "" && !isDigits(fraction)) {
+		return 0, false
+	}
+	cents, _ = strconv.ParseInt(dollars, 10, 64)
+	cents *= 100
+	if fraction != "" {
+		c, _ := strconv.ParseInt((fraction + "0")[:2], 10, 64)
+		cents += c
+	}
+	return cents, true
+}
+
+// FormatCents formats an amount in cents as dollars, ex. "$50" or "$12.50".
+func FormatCents(cents int64) string {
+	if cents%100 == 0 {
+		return fmt.Sprintf("$%d", cents/100)
+	}
+	return fmt.Sprintf("$%d.%02d", cents/100, cents%100)
+}
+
+// GetCredit returns the credit value of s.
+func (s Sect) GetCredit() SectCredit {
+	return SectCredit{s.MinCredits, s.MaxCredits, s.VariableCredits, s.Quiz}
+}
+
+// Totals returns the range of credits and the fees of taking all of sects.
+// Sections with "VAR" credits add nothing to the range.
+func Totals(sects []Sect) (minCredits, maxCredits, feeCents int64) {
+	for _, sect := range sects {
+		minCredits += sect.MinCredits
+		maxCredits += sect.MaxCredits
+		feeCents += sect.FeeCents
+	}
+	return minCredits, maxCredits, feeCents
+}
@@ -0,0 +1,62 @@
+package goschedule
+
+import (
+	"testing"
+)
+
+func TestParseSectCredit(t *testing.T) {
+	testSet := []struct {
+		s        string
+		expected SectCredit
+	}{
+		{"5", SectCredit{Min: 5, Max: 5}},
+		{" 2-5 ", SectCredit{Min: 2, Max: 5, Variable: true}},
+		{"3-3", SectCredit{Min: 3, Max: 3}},
+		{"VAR", SectCredit{Variable: true}},
+		{"QZ", SectCredit{Quiz: true}},
+		{"LB", SectCredit{}},
+		{"", SectCredit{}},
+	}
+	for _, test := range testSet {
+		if credit := ParseSectCredit(test.s); credit != test.expected {
+			t.Errorf("ParseSectCredit(%q) = %+v, expected %+v", test.s, credit, test.expected)
+		}
+	}
+}
+
+func TestParseFee(t *testing.T) {
+	testSet := []struct {
+		s     string
+		cents int64
+		ok    bool
+	}{
+		{"$50", 5000, true},
+		{"$1,250.5", 125050, true},
+		{"$12.05", 1205, true},
+		{"", 0, false},
+		{"$", 0, false},
+		{"$12.345", 0, false},
+		{"fee", 0, false},
+	}
+	for _, test := range testSet {
+		if cents, ok := ParseFee(test.s); cents != test.cents || ok != test.ok {
+			t.Errorf("ParseFee(%q) = %d, %v, expected %d, %v", test.s, cents, ok, test.cents, test.ok)
+		}
+	}
+	for cents, expected := range map[int64]string{5000: "$50", 1205: "$12.05", 0: "$0"} {
+		if s := FormatCents(cents); s != expected {
+			t.Errorf("FormatCents(%d) = %q, expected %q", cents, s, expected)
+		}
+	}
+}
+
+func TestTotals(t *testing.T) {
+	sects := []Sect{
+		{MinCredits: 5, MaxCredits: 5, FeeCents: 5000},
+		{Quiz: true},
+		{MinCredits: 1, MaxCredits: 3, VariableCredits: true, FeeCents: 250},
+	}
+	if min, max, fee := Totals(sects); min != 6 || max != 8 || fee != 5250 {
+		t.Errorf("Totals = %d, %d, %d, expected 6, 8, 5250", min, max, fee)
+	}
+}
@@ -25,14 +25,16 @@ func GenerateSchema(s interface{}) string {
 		}
 		// add column name
 		columns += sType.Field(i).Name
-		// add column type
-		switch field := sValue.Field(i).Interface(); field.(type) {
-		case string:
+		// add column type (by kind, so named types like Flags are supported)
+		switch field := sValue.Field(i); field.Kind() {
+		case reflect.String:
 			columns += " text"
-		case int64:
+		case reflect.Int64:
 			columns += " integer"
+		case reflect.Bool:
+			columns += " boolean"
 		default:
-			panic(fmt.Sprintf("goschedule.GenerateSchema: invalid struct field type: %T", field))
+			panic(fmt.Sprintf("goschedule.GenerateSchema: invalid struct field type: %s", field.Type()))
 		}
 		// add PRIMARY KEY restraint if found
 		switch pk := sType.Field(i).Tag.Get("pk"); pk {
@@ -68,11 +70,14 @@ func GenerateSchema(s interface{}) string {
 // For example `select(db, Sect{}, "ORDER BY sln LIMIT 5")` runs the query:
 //
 //	SELECT * FROM sect ORDER BY sln LIMIT 5;
-func Select(db *sql.DB, object interface{}, conditions string) ([]interface{}, error) {
+//
+// Values from users must be passed in args and referred to by placeholders
+// in conditions, ex. `Select(db, Sect{}, "WHERE classkey = $1", key)`.
+func Select(db *sql.DB, object interface{}, conditions string, args ...interface{}) ([]interface{}, error) {
 	tableName := reflect.TypeOf(object).Name()
 	query := fmt.Sprintf("SELECT * FROM %s %s", tableName, conditions)
 	// execute query
-	rows, err := db.Query(query)
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -108,8 +113,12 @@ func Select(db *sql.DB, object interface{}, conditions string) ([]interface{}, e
 			switch valueInterface.(type) {
 			case []uint8:
 				field.SetString(string(valueInterface.([]uint8)))
+			case string:
+				field.SetString(valueInterface.(string))
 			case int64:
 				field.SetInt(valueInterface.(int64))
+			case bool:
+				field.SetBool(valueInterface.(bool))
 			default:
 				panic(fmt.Sprintf("goschedule.Select error: type unsupported `%T`. Edit goschedule.Select source to implement the type or use a supported type.", valueInterface))
 			}
@@ -20,7 +20,7 @@ type position struct {
 type College struct {
 	Name         string
 	Abbreviation string `pk:"true"`
-	position     `ignore:"true"`
+	position     `ignore:"true" json:"-"`
 }
 
 // A Department is a UW department that has many classes.
@@ -54,7 +54,77 @@ type Class struct {
 	Code             string
 	Name             string
 	Description      string
-	position         `ignore:"true"`
+	Credits          string
+	MinCredits       int64
+	MaxCredits       int64
+	Areas            string // comma separated areas of knowledge
+	Prerequisites    string // JSON representation
+	Offered          string // quarter abbreviations, ex. "AWSp"
+	position         `ignore:"true" json:"-"`
+}
+
+// SetCatalogEntry copies the parsed parts of a course catalog entry into the
+// description fields of c.
+func (c *Class) SetCatalogEntry(entry CatalogEntry) error {
+	c.Description = entry.Description
+	c.Credits = entry.Credits.Raw
+	c.MinCredits = entry.Credits.Min
+	c.MaxCredits = entry.Credits.Max
+	c.Areas = strings.Join(entry.Areas, ", ")
+	c.Offered = strings.Join(entry.Offered, "")
+	c.Prerequisites = ""
+	if entry.Prerequisites != nil {
+		prereqJson, err := json.Marshal(entry.Prerequisites)
+		if err != nil {
+			return err
+		}
+		c.Prerequisites = string(prereqJson)
+	}
+	return nil
+}
+
+// GetPrerequisites parses the JSON representation of the prerequisites of
+// the Class. Returns nil if the Class has no prerequisites.
+func (c Class) GetPrerequisites() (*Prereq, error) {
+	if c.Prerequisites == "" {
+		return nil, nil
+	}
+	var prereq Prereq
+	if err := json.Unmarshal([]byte(c.Prerequisites), &prereq); err != nil {
+		return nil, err
+	}
+	return &prereq, nil
+}
+
+// GetAreas returns the areas of knowledge (ex. "NW", "QSR") of the Class.
+func (c Class) GetAreas() []string {
+	if c.Areas == "" {
+		return nil
+	}
+	return strings.Split(c.Areas, ", ")
+}
+
+// GetOffered returns the names of the quarters the Class is usually offered.
+func (c Class) GetOffered() []string {
+	var quarters []string
+	for _, quarter := range splitQuarters(c.Offered) {
+		quarters = append(quarters, QuarterName(quarter))
+	}
+	return quarters
+}
+
+// A ClassPrereq records that a Class names another class in its
+// prerequisites. PrereqKey is not a foreign key since the other class may
+// not be offered this quarter.
+type ClassPrereq struct {
+	ClassKey  string `fk:"Class"`
+	PrereqKey string
+}
+
+// A ClassJoint records that a Class is jointly offered with another class.
+type ClassJoint struct {
+	ClassKey string `fk:"Class"`
+	JointKey string
 }
 
 // DescriptionHTML outputs non-escaped HTML of a Class.Description for use in a template.
@@ -77,20 +147,28 @@ func (c Class) DescriptionHTML() template.HTML {
 
 // A Sect is a UW section.
 type Sect struct {
-	ClassKey     string `fk:"Class"`
-	Restriction  string
-	SLN          string `pk:"true"`
-	Section      string
-	Credit       string
-	MeetingTimes string // JSON representation
-	Instructor   string
-	Status       string
-	TakenSpots   int64
-	TotalSpots   int64
-	Grades       string
-	Fee          string
-	Other        string
-	Info         string
+	ClassKey        string `fk:"Class"`
+	Restriction     string
+	SLN             string `pk:"true"`
+	Section         string
+	Credit          string
+	MeetingTimes    string // JSON representation
+	Instructor      string
+	Status          string
+	TakenSpots      int64
+	TotalSpots      int64
+	Grades          string
+	Fee             string
+	Other           string
+	Info            string
+	LectureKey      string // SLN of the lecture of a quiz or lab section, else empty
+	InstructorKey   string // Instructor.Key, or empty if no instructor is listed
+	Flags           Flags  // parsed from Restriction, Grades and Other
+	FeeCents        int64  // parsed from Fee, 0 if there is no fee
+	MinCredits      int64  // parsed from Credit, see SectCredit
+	MaxCredits      int64
+	VariableCredits bool
+	Quiz            bool
 }
 
 // GetMeetingTimes parses the JSON representation of meeting
@@ -114,6 +192,42 @@ func (s Sect) IsQuizSection() bool {
 	}
 }
 
+// IsLecture indicates if this Sect is a lecture, as opposed to a quiz or lab
+// section belonging to one.
+func (s Sect) IsLecture() bool {
+	return s.LectureKey == ""
+}
+
+// A SectGroup is a lecture Sect and its quiz and lab sections.
+type SectGroup struct {
+	Lecture  Sect
+	Sections []Sect
+}
+
+// GroupSects groups sects by lecture using Sect.LectureKey, keeping the order
+// of sects. A section whose lecture is not in sects is put in its own group.
+func GroupSects(sects []Sect) []SectGroup {
+	var groups []SectGroup
+	indices := make(map[string]int)
+	for _, sect := range sects {
+		if sect.IsLecture() {
+			indices[sect.SLN] = len(groups)
+			groups = append(groups, SectGroup{Lecture: sect})
+		}
+	}
+	for _, sect := range sects {
+		if sect.IsLecture() {
+			continue
+		}
+		if i, ok := indices[sect.LectureKey]; ok {
+			groups[i].Sections = append(groups[i].Sections, sect)
+		} else {
+			groups = append(groups, SectGroup{Lecture: sect})
+		}
+	}
+	return groups
+}
+
 // IsOpen indicates if this Sect has open spots.
 func (s Sect) IsOpen() bool {
 	if s.TotalSpots-s.TakenSpots < 1 {
@@ -167,49 +281,19 @@ func (s Sect) IsWithdrawal() bool {
 	return false
 }
 
-// GetRestriction returns a map of possible restriction symbols to booleans depending
-// on if they apply to this Sect.
-func (s Sect) GetRestriction() []map[string]bool {
-	allTokens := []string{"Restr", "IS", ">"}
-	tokens := make([]map[string]bool, len(allTokens))
-	for i, v := range allTokens {
-		if strings.Contains(s.Restriction, v) {
-			tokens[i] = map[string]bool{v: true}
-		} else {
-			tokens[i] = map[string]bool{v: false}
-		}
-	}
-	return tokens
+// RestrictionFlags returns the state of each flag of the Restr column.
+func (s Sect) RestrictionFlags() []FlagState {
+	return s.Flags.States(RestrictionFlags)
 }
 
-// GetGradesTokens returns a map of possible grade tokens to booleans depending
-// on if they apply to this Sect.
-func (s Sect) GetGradesTokens() []map[string]bool {
-	allTokens := []string{"CR/NC"}
-	tokens := make([]map[string]bool, len(allTokens))
-	for i, v := range allTokens {
-		if strings.Contains(s.Credit, v) {
-			tokens[i] = map[string]bool{v: true}
-		} else {
-			tokens[i] = map[string]bool{v: false}
-		}
-	}
-	return tokens
+// GradesFlags returns the state of each flag of the Grades column.
+func (s Sect) GradesFlags() []FlagState {
+	return s.Flags.States(GradesFlags)
 }
 
-// GetOtherTokens returns a map of possible other tokens to booleans depending
-// on if they apply to this Sect.
-func (s Sect) GetOtherTokens() []map[string]bool {
-	allTokens := []string{"D", "H", "J", "R", "S", "W", "%", "#"}
-	tokens := make([]map[string]bool, len(allTokens))
-	for i, v := range allTokens {
-		if strings.Contains(s.Other, v) {
-			tokens[i] = map[string]bool{v: true}
-		} else {
-			tokens[i] = map[string]bool{v: false}
-		}
-	}
-	return tokens
+// OtherFlags returns the state of each flag of the Other column.
+func (s Sect) OtherFlags() []FlagState {
+	return s.Flags.States(OtherFlags)
 }
 
 // A MeetingTime represents when a Sect is held. Some Sect's have multiple
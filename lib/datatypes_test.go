@@ -0,0 +1,23 @@
+package goschedule
+
+import (
+	"testing"
+)
+
+func TestGroupSects(t *testing.T) {
+	sects := []Sect{
+		{SLN: "1", Section: "A"},
+		{SLN: "2", Section: "AA", LectureKey: "1"},
+		{SLN: "3", Section: "AB", LectureKey: "1"},
+		{SLN: "4", Section: "B"},
+		{SLN: "5", Section: "BA", LectureKey: "4"},
+		{SLN: "6", Section: "CA"},
+	}
+	groups := GroupSects(sects)
+	if len(groups) != 3 {
+		t.Fatalf("got %d groups", len(groups))
+	}
+	if len(groups[0].Sections) != 2 || len(groups[1].Sections) != 1 || groups[2].Lecture.Section != "CA" {
+		t.Errorf("got groups %+v", groups)
+	}
+}
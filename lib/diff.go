@@ -0,0 +1,220 @@
+package goschedule
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A ChangeKind is the kind of a Change.
+type ChangeKind string
+
+// Kinds of changes between two scrapes.
+const (
+	ClassAdded            ChangeKind = "class-added"
+	ClassRemoved          ChangeKind = "class-removed"
+	SectAdded             ChangeKind = "section-added"
+	SectCancelled         ChangeKind = "section-cancelled"
+	SectTimeChanged       ChangeKind = "time-changed"
+	SectRoomChanged       ChangeKind = "room-changed"
+	SectInstructorChanged ChangeKind = "instructor-changed"
+	SectStatusChanged     ChangeKind = "status-changed"
+)
+
+// changeKinds lists every ChangeKind in the order changes to the same class
+// or section are sorted in.
+var changeKinds = []ChangeKind{
+	ClassAdded, ClassRemoved, SectAdded, SectCancelled,
+	SectTimeChanged, SectRoomChanged, SectInstructorChanged, SectStatusChanged,
+}
+
+// A Change is a difference found between two scrapes of a time schedule.
+// Changes are stored in the switch database, which outlives the application
+// databases they are computed from.
+type Change struct {
+	Time     string // RFC 3339 time the change was found
+	Kind     ChangeKind
+	DeptKey  string
+	ClassKey string
+	SLN      string // empty for class changes
+	Section  string
+	Old      string // for changes to a field, its old and new value
+	New      string
+}
+
+// GetTime parses Change.Time. It returns the zero time if Time is malformed.
+func (c Change) GetTime() time.Time {
+	t, _ := time.Parse(time.RFC3339, c.Time)
+	return t
+}
+
+// Summary describes c in a line, ex. "CSE 142 A: time changed from
+// MWF 930-1020 to MWF 1030-1120".
+func (c Change) Summary() string {
+	subject := strings.ToUpper(c.ClassKey)
+	if c.SLN != "" {
+		subject = fmt.Sprintf("%s %s (SLN %s)", subject, strings.ToUpper(c.Section), c.SLN)
+	}
+	switch c.Kind {
+	case ClassAdded, SectAdded:
+		return subject + ": added"
+	case ClassRemoved:
+		return subject + ": removed"
+	case SectCancelled:
+		return subject + ": cancelled"
+	}
+	field := strings.TrimSuffix(string(c.Kind), "-changed")
+	return fmt.Sprintf("%s: %s changed from %q to %q", subject, field, c.Old, c.New)
+}
+
+// Diff compares the classes and sections of two scrapes by primary key and
+// returns the changes from the old to the new, found at time t. Changes are
+// sorted by department, class, section and kind.
+func Diff(oldClasses []Class, oldSects []Sect, newClasses []Class, newSects []Sect, t time.Time) []Change {
+	stamp := t.UTC().Format(time.RFC3339)
+	deptKeys := make(map[string]string)
+	oldClassMap := make(map[string]Class)
+	for _, class := range oldClasses {
+		oldClassMap[class.AbbreviationCode] = class
+		deptKeys[class.AbbreviationCode] = class.DeptKey
+	}
+	newClassMap := make(map[string]Class)
+	for _, class := range newClasses {
+		newClassMap[class.AbbreviationCode] = class
+		deptKeys[class.AbbreviationCode] = class.DeptKey
+	}
+	var changes []Change
+	classChange := func(kind ChangeKind, class Class) {
+		changes = append(changes, Change{Time: stamp, Kind: kind, DeptKey: class.DeptKey, ClassKey: class.AbbreviationCode})
+	}
+	for key, class := range newClassMap {
+		if _, ok := oldClassMap[key]; !ok {
+			classChange(ClassAdded, class)
+		}
+	}
+	for key, class := range oldClassMap {
+		if _, ok := newClassMap[key]; !ok {
+			classChange(ClassRemoved, class)
+		}
+	}
+	sectChange := func(kind ChangeKind, sect Sect, old, new string) {
+		changes = append(changes, Change{
+			Time:     stamp,
+			Kind:     kind,
+			DeptKey:  deptKeys[sect.ClassKey],
+			ClassKey: sect.ClassKey,
+			SLN:      sect.SLN,
+			Section:  sect.Section,
+			Old:      old,
+			New:      new,
+		})
+	}
+	oldSectMap := make(map[string]Sect)
+	for _, sect := range oldSects {
+		oldSectMap[sect.SLN] = sect
+	}
+	newSectMap := make(map[string]Sect)
+	for _, sect := range newSects {
+		newSectMap[sect.SLN] = sect
+		old, ok := oldSectMap[sect.SLN]
+		if !ok {
+			sectChange(SectAdded, sect, "", "")
+			continue
+		}
+		oldTimes, oldRooms := old.meetingSummaries()
+		newTimes, newRooms := sect.meetingSummaries()
+		for _, field := range []struct {
+			kind     ChangeKind
+			old, new string
+		}{
+			{SectTimeChanged, oldTimes, newTimes},
+			{SectRoomChanged, oldRooms, newRooms},
+			{SectInstructorChanged, old.Instructor, sect.Instructor},
+			{SectStatusChanged, old.Status, sect.Status},
+		} {
+			if field.old != field.new {
+				sectChange(field.kind, sect, field.old, field.new)
+			}
+		}
+	}
+	for _, sect := range oldSects {
+		if _, ok := newSectMap[sect.SLN]; !ok {
+			sectChange(SectCancelled, sect, "", "")
+		}
+	}
+	kindOrder := make(map[ChangeKind]int)
+	for i, kind := range changeKinds {
+		kindOrder[kind] = i
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		switch {
+		case a.DeptKey != b.DeptKey:
+			return a.DeptKey < b.DeptKey
+		case a.ClassKey != b.ClassKey:
+			return a.ClassKey < b.ClassKey
+		case a.Section != b.Section:
+			return a.Section < b.Section
+		}
+		return kindOrder[a.Kind] < kindOrder[b.Kind]
+	})
+	return changes
+}
+
+// meetingSummaries returns the days and times, and the buildings and rooms,
+// of the meeting times of s, each joined by "; ". The raw JSON is returned as
+// the times if it cannot be parsed.
+func (s Sect) meetingSummaries() (times, rooms string) {
+	meetingTimes, err := s.GetMeetingTimes()
+	if err != nil {
+		return s.MeetingTimes, ""
+	}
+	var timeList, roomList []string
+	for _, mt := range meetingTimes {
+		timeList = append(timeList, strings.TrimSpace(mt.Days+" "+mt.Time))
+		roomList = append(roomList, strings.TrimSpace(mt.Building+" "+mt.Room))
+	}
+	return strings.Join(timeList, "; "), strings.Join(roomList, "; ")
+}
+
+// DiffDatabases loads the classes and sections of two application databases
+// and returns the changes from oldDb to newDb, found at time t. If oldDb has
+// no sections, as before the first scrape, there is nothing to compare with
+// and no changes are returned.
+func DiffDatabases(oldDb, newDb *sql.DB, t time.Time) ([]Change, error) {
+	oldClasses, oldSects, err := loadSchedule(oldDb)
+	if err != nil {
+		return nil, err
+	}
+	if len(oldSects) == 0 {
+		return nil, nil
+	}
+	newClasses, newSects, err := loadSchedule(newDb)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(oldClasses, oldSects, newClasses, newSects, t), nil
+}
+
+// loadSchedule selects every class and section of an application database.
+func loadSchedule(db *sql.DB) ([]Class, []Sect, error) {
+	classRecords, err := Select(db, Class{}, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	var classes []Class
+	for _, v := range classRecords {
+		classes = append(classes, v.(Class))
+	}
+	sectRecords, err := Select(db, Sect{}, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	var sects []Sect
+	for _, v := range sectRecords {
+		sects = append(sects, v.(Sect))
+	}
+	return classes, sects, nil
+}
@@ -0,0 +1,57 @@
+package goschedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	oldClasses := []Class{
+		{DeptKey: "cse", AbbreviationCode: "cse142"},
+		{DeptKey: "cse", AbbreviationCode: "cse143"},
+	}
+	newClasses := []Class{
+		{DeptKey: "cse", AbbreviationCode: "cse142"},
+		{DeptKey: "cse", AbbreviationCode: "cse154"},
+	}
+	oldSects := []Sect{
+		{ClassKey: "cse142", SLN: "1", Section: "a", Instructor: "REGES,STUART", Status: "open", MeetingTimes: `[{"Days":"MWF","Time":"930-1020","Building":"KNE","Room":"130"}]`},
+		{ClassKey: "cse142", SLN: "2", Section: "aa", Status: "open", MeetingTimes: `[]`},
+		{ClassKey: "cse143", SLN: "3", Section: "a", Status: "open", MeetingTimes: `[]`},
+	}
+	newSects := []Sect{
+		{ClassKey: "cse142", SLN: "1", Section: "a", Instructor: "STEPP,MARTY", Status: "open", MeetingTimes: `[{"Days":"MWF","Time":"1030-1120","Building":"GUG","Room":"220"}]`},
+		{ClassKey: "cse142", SLN: "2", Section: "aa", Status: "closed", MeetingTimes: `[]`},
+		{ClassKey: "cse154", SLN: "4", Section: "a", Status: "open", MeetingTimes: `[]`},
+	}
+	found := time.Date(2013, 8, 1, 12, 0, 0, 0, time.UTC)
+	expected := []Change{
+		{Kind: SectTimeChanged, ClassKey: "cse142", SLN: "1", Old: "MWF 930-1020", New: "MWF 1030-1120"},
+		{Kind: SectRoomChanged, ClassKey: "cse142", SLN: "1", Old: "KNE 130", New: "GUG 220"},
+		{Kind: SectInstructorChanged, ClassKey: "cse142", SLN: "1", Old: "REGES,STUART", New: "STEPP,MARTY"},
+		{Kind: SectStatusChanged, ClassKey: "cse142", SLN: "2", Old: "open", New: "closed"},
+		{Kind: ClassRemoved, ClassKey: "cse143"},
+		{Kind: SectCancelled, ClassKey: "cse143", SLN: "3"},
+		{Kind: ClassAdded, ClassKey: "cse154"},
+		{Kind: SectAdded, ClassKey: "cse154", SLN: "4"},
+	}
+	changes := Diff(oldClasses, oldSects, newClasses, newSects, found)
+	if len(changes) != len(expected) {
+		t.Fatalf("got %d changes, expected %d: %+v", len(changes), len(expected), changes)
+	}
+	for i, change := range changes {
+		e := expected[i]
+		if change.Kind != e.Kind || change.ClassKey != e.ClassKey || change.SLN != e.SLN || change.Old != e.Old || change.New != e.New {
+			t.Errorf("change %d: got %+v, expected %+v", i, change, e)
+		}
+		if change.DeptKey != "cse" {
+			t.Errorf("change %d: got DeptKey %q", i, change.DeptKey)
+		}
+		if !change.GetTime().Equal(found) {
+			t.Errorf("change %d: got time %q", i, change.Time)
+		}
+	}
+	if summary := changes[0].Summary(); summary != `CSE142 A (SLN 1): time changed from "MWF 930-1020" to "MWF 1030-1120"` {
+		t.Errorf("Summary() = %q", summary)
+	}
+}
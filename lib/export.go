@@ -0,0 +1,429 @@
+package goschedule
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// TermVersion is the version of the export format written by this package.
+// It is incremented whenever a field is renamed or removed, or its meaning
+// changes.
+//
+// Version 1 holds the colleges, departments, classes and sections of a term:
+//
+//   - JSON: a single Term object. Records have the fields of College, Dept,
+//     Class and Sect, except that the MeetingTimes of a section are a list of
+//     MeetingTime objects instead of a JSON string.
+//   - CSV: a directory with term.csv (the Version, Schedule and Exported
+//     fields of the Term), colleges.csv, depts.csv, classes.csv and
+//     sects.csv. Each file has a header row of field names; MeetingTimes is
+//     a JSON string and booleans are "true" or "false".
+//   - SQLite: a database with a TermInfo table holding the Version, Schedule
+//     and Exported fields, and College, Dept, Class and Sect tables as
+//     created by GenerateSchema.
+//
+// Version 2 adds the joint listings of classes, ClassJoint records: the
+// ClassJoints field of the Term object, classjoints.csv and a ClassJoint
+// table. Version 1 exports are still read, without joint listings.
+//
+// Version 3 adds the instructors of sections, Instructor records: the
+// Instructors field of the Term object, instructors.csv and an Instructor
+// table. The instructors of earlier exports are parsed from their sections
+// when stored.
+const TermVersion = 3
+
+// A Term is the exported data of a time schedule.
+type Term struct {
+	Version  int
+	Schedule string // schedule name from the config, ex. "aut2013"
+	Exported string // RFC 3339 time of the export
+	Colleges []College
+	Depts    []Dept
+	Classes  []Class
+	Sects    []TermSect
+	// ClassJoints are the joint listings of classes, since version 2
+	ClassJoints []ClassJoint
+	// Instructors are the instructors of sections, since version 3
+	Instructors []Instructor
+}
+
+// A TermSect is a Sect with its meeting times parsed.
+type TermSect struct {
+	Sect
+	MeetingTimes []MeetingTime
+}
+
+// TermInfo is the row of the TermInfo table of a SQLite export.
+type TermInfo struct {
+	Version  int64
+	Schedule string
+	Exported string
+}
+
+// termTables are the record types of a Term, in the order they are stored.
+var termTables = []interface{}{College{}, Dept{}, Class{}, Sect{}, ClassJoint{}, Instructor{}}
+
+// versionTables returns the record types of termTables in exports of the
+// version of t.
+func (t Term) versionTables() []interface{} {
+	switch {
+	case t.Version < 2:
+		return termTables[:4]
+	case t.Version < 3:
+		return termTables[:5]
+	}
+	return termTables
+}
+
+// LoadTerm reads the colleges, departments, classes, sections, joint listings
+// and instructors of a database into a Term.
+func LoadTerm(db *sql.DB, schedule string, exported time.Time) (Term, error) {
+	term := Term{Version: TermVersion, Schedule: schedule, Exported: exported.UTC().Format(time.RFC3339)}
+	for _, table := range termTables {
+		records, err := Select(db, table, "")
+		if err != nil {
+			return Term{}, err
+		}
+		if err := term.add(records); err != nil {
+			return Term{}, err
+		}
+	}
+	return term, nil
+}
+
+// add appends records of one of termTables to t.
+func (t *Term) add(records []interface{}) error {
+	for _, v := range records {
+		switch record := v.(type) {
+		case College:
+			t.Colleges = append(t.Colleges, record)
+		case Dept:
+			t.Depts = append(t.Depts, record)
+		case Class:
+			t.Classes = append(t.Classes, record)
+		case ClassJoint:
+			t.ClassJoints = append(t.ClassJoints, record)
+		case Instructor:
+			t.Instructors = append(t.Instructors, record)
+		case Sect:
+			meetingTimes, err := record.GetMeetingTimes()
+			if err != nil {
+				return fmt.Errorf("section %s: %v", record.SLN, err)
+			}
+			// the JSON string is rebuilt from TermSect.MeetingTimes when stored
+			record.MeetingTimes = ""
+			t.Sects = append(t.Sects, TermSect{record, meetingTimes})
+		}
+	}
+	return nil
+}
+
+// records returns the records of t of the same type as table, one of
+// termTables, with the meeting times of sections encoded as JSON.
+func (t Term) records(table interface{}) ([]interface{}, error) {
+	var records []interface{}
+	switch table.(type) {
+	case College:
+		for _, college := range t.Colleges {
+			records = append(records, college)
+		}
+	case Dept:
+		for _, dept := range t.Depts {
+			records = append(records, dept)
+		}
+	case Class:
+		for _, class := range t.Classes {
+			records = append(records, class)
+		}
+	case Sect:
+		for _, sect := range t.Sects {
+			meetingTimes, err := json.Marshal(sect.MeetingTimes)
+			if err != nil {
+				return nil, err
+			}
+			if sect.MeetingTimes == nil {
+				meetingTimes = []byte("[]")
+			}
+			sect.Sect.MeetingTimes = string(meetingTimes)
+			records = append(records, sect.Sect)
+		}
+	case ClassJoint:
+		for _, joint := range t.ClassJoints {
+			records = append(records, joint)
+		}
+	case Instructor:
+		for _, instructor := range t.Instructors {
+			records = append(records, instructor)
+		}
+	}
+	return records, nil
+}
+
+// StoreTerm inserts the records of t into db, which must have the tables of
+// an application database. The class prerequisites derived from the records
+// are stored too, and so are the instructors parsed from the sections of an
+// export before version 3, which has none.
+func StoreTerm(db *sql.DB, t Term) error {
+	for _, table := range termTables {
+		records, err := t.records(table)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := Insert(db, record); err != nil {
+				return err
+			}
+		}
+	}
+	if t.Version < 3 {
+		instructors := make(map[string]bool)
+		for _, sect := range t.Sects {
+			if instructor, ok := ParseInstructor(sect.Instructor); ok && !instructors[instructor.Key] {
+				instructors[instructor.Key] = true
+				if err := Insert(db, instructor); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, class := range t.Classes {
+		prereq, err := class.GetPrerequisites()
+		if err != nil {
+			return err
+		}
+		for _, prereqKey := range prereq.Courses() {
+			if err := Insert(db, ClassPrereq{ClassKey: class.AbbreviationCode, PrereqKey: prereqKey}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkVersion returns an error if t is not in a version this package reads.
+func (t Term) checkVersion() error {
+	if t.Version < 1 || t.Version > TermVersion {
+		return fmt.Errorf("unsupported export version %d (expected at most %d)", t.Version, TermVersion)
+	}
+	return nil
+}
+
+// WriteTermJSON writes t to w as JSON.
+func WriteTermJSON(w io.Writer, t Term) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(t)
+}
+
+// ReadTermJSON reads a Term written by WriteTermJSON.
+func ReadTermJSON(r io.Reader) (Term, error) {
+	var t Term
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return Term{}, err
+	}
+	return t, t.checkVersion()
+}
+
+// termCSVFiles maps termTables to the files of a CSV export.
+var termCSVFiles = []string{"colleges.csv", "depts.csv", "classes.csv", "sects.csv", "classjoints.csv", "instructors.csv"}
+
+// WriteTermCSV writes t to CSV files in dir, which is created if needed.
+func WriteTermCSV(dir string, t Term) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := writeCSVFile(filepath.Join(dir, "term.csv"), TermInfo{}, []interface{}{TermInfo{int64(t.Version), t.Schedule, t.Exported}}); err != nil {
+		return err
+	}
+	for i, table := range termTables {
+		records, err := t.records(table)
+		if err != nil {
+			return err
+		}
+		if err := writeCSVFile(filepath.Join(dir, termCSVFiles[i]), table, records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadTermCSV reads a Term written by WriteTermCSV.
+func ReadTermCSV(dir string) (Term, error) {
+	infos, err := readCSVFile(filepath.Join(dir, "term.csv"), TermInfo{})
+	if err != nil {
+		return Term{}, err
+	}
+	if len(infos) != 1 {
+		return Term{}, fmt.Errorf("term.csv: expected 1 record, found %d", len(infos))
+	}
+	info := infos[0].(TermInfo)
+	t := Term{Version: int(info.Version), Schedule: info.Schedule, Exported: info.Exported}
+	if err := t.checkVersion(); err != nil {
+		return Term{}, err
+	}
+	for i, table := range t.versionTables() {
+		records, err := readCSVFile(filepath.Join(dir, termCSVFiles[i]), table)
+		if err != nil {
+			return Term{}, err
+		}
+		if err := t.add(records); err != nil {
+			return Term{}, err
+		}
+	}
+	return t, nil
+}
+
+// ReadTermSQL reads a Term from a database written by WriteTermSQL.
+func ReadTermSQL(db *sql.DB) (Term, error) {
+	infos, err := Select(db, TermInfo{}, "")
+	if err != nil {
+		return Term{}, err
+	}
+	if len(infos) != 1 {
+		return Term{}, fmt.Errorf("TermInfo: expected 1 record, found %d", len(infos))
+	}
+	info := infos[0].(TermInfo)
+	t := Term{Version: int(info.Version), Schedule: info.Schedule, Exported: info.Exported}
+	if err := t.checkVersion(); err != nil {
+		return Term{}, err
+	}
+	for _, table := range t.versionTables() {
+		records, err := Select(db, table, "")
+		if err != nil {
+			return Term{}, err
+		}
+		if err := t.add(records); err != nil {
+			return Term{}, err
+		}
+	}
+	return t, nil
+}
+
+// WriteTermSQL creates the tables of a SQL export in an empty database, such
+// as a new SQLite file, and writes t to them.
+func WriteTermSQL(db *sql.DB, t Term) error {
+	for _, table := range append([]interface{}{TermInfo{}}, termTables...) {
+		if _, err := db.Exec(GenerateSchema(table)); err != nil {
+			return err
+		}
+	}
+	if err := Insert(db, TermInfo{int64(t.Version), t.Schedule, t.Exported}); err != nil {
+		return err
+	}
+	for _, table := range termTables {
+		records, err := t.records(table)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := Insert(db, record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// csvFields returns the indices of the fields of t stored in a CSV file: those
+// without the `ignore:"true"` tag.
+func csvFields(t reflect.Type) []int {
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("ignore") != "true" {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+// writeCSVFile writes records, of the same struct type as table, to a CSV
+// file with a header row of field names.
+func writeCSVFile(path string, table interface{}, records []interface{}) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	tableType := reflect.TypeOf(table)
+	fields := csvFields(tableType)
+	header := make([]string, len(fields))
+	for i, field := range fields {
+		header[i] = tableType.Field(field).Name
+	}
+	w.Write(header)
+	for _, record := range records {
+		value := reflect.ValueOf(record)
+		row := make([]string, len(fields))
+		for i, field := range fields {
+			switch f := value.Field(field); f.Kind() {
+			case reflect.String:
+				row[i] = f.String()
+			case reflect.Int64:
+				row[i] = strconv.FormatInt(f.Int(), 10)
+			case reflect.Bool:
+				row[i] = strconv.FormatBool(f.Bool())
+			}
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// readCSVFile reads records of the same struct type as table from a file
+// written by writeCSVFile. Columns are matched to fields by name.
+func readCSVFile(path string, table interface{}) ([]interface{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: missing header row", filepath.Base(path))
+	}
+	tableType := reflect.TypeOf(table)
+	var records []interface{}
+	for line, row := range rows[1:] {
+		record := reflect.New(tableType).Elem()
+		for i, name := range rows[0] {
+			field := record.FieldByName(name)
+			if !field.IsValid() || i >= len(row) {
+				continue
+			}
+			switch field.Kind() {
+			case reflect.String:
+				field.SetString(row[i])
+			case reflect.Int64:
+				n, err := strconv.ParseInt(row[i], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %s: %v", filepath.Base(path), line+2, name, err)
+				}
+				field.SetInt(n)
+			case reflect.Bool:
+				b, err := strconv.ParseBool(row[i])
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %s: %v", filepath.Base(path), line+2, name, err)
+				}
+				field.SetBool(b)
+			}
+		}
+		records = append(records, record.Interface())
+	}
+	return records, nil
+}
@@ -0,0 +1,102 @@
+package goschedule
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testTerm() Term {
+	return Term{
+		Version:  TermVersion,
+		Schedule: "aut2013",
+		Exported: "2013-08-01T12:00:00Z",
+		Colleges: []College{{Name: "Engineering", Abbreviation: "eng"}},
+		Depts:    []Dept{{CollegeKey: "eng", Name: "Computer Science", Abbreviation: "cse", Link: "cse.html"}},
+		Classes:  []Class{{DeptKey: "cse", AbbreviationCode: "cse142", Abbreviation: "cse", Code: "142", Name: "COMPUTER PRG I", MinCredits: 4, MaxCredits: 4}},
+		Sects: []TermSect{
+			{
+				Sect:         Sect{ClassKey: "cse142", SLN: "12345", Section: "a", Credit: "4", Instructor: "REGES,STUART", InstructorKey: "reges-stuart", Info: "line one,\n\"quoted\"", MinCredits: 4, MaxCredits: 4, Flags: Flags(FlagWriting)},
+				MeetingTimes: []MeetingTime{{"MWF", "930-1020", "KNE", "130"}},
+			},
+			{
+				Sect:         Sect{ClassKey: "cse142", SLN: "12346", Section: "aa", Credit: "QZ", Quiz: true, LectureKey: "12345"},
+				MeetingTimes: []MeetingTime{},
+			},
+		},
+		ClassJoints: []ClassJoint{{ClassKey: "cse142", JointKey: "ee142"}},
+		Instructors: []Instructor{{Key: "reges-stuart", Name: "Stuart Reges", Last: "Reges", First: "Stuart"}},
+	}
+}
+
+func TestTermJSON(t *testing.T) {
+	term := testTerm()
+	var buf bytes.Buffer
+	if err := WriteTermJSON(&buf, term); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadTermJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, term) {
+		t.Errorf("got %+v, expected %+v", read, term)
+	}
+	if _, err := ReadTermJSON(bytes.NewBufferString(`{"Version": 99}`)); err == nil {
+		t.Errorf("expected an error for an unsupported version")
+	}
+}
+
+func TestTermCSV(t *testing.T) {
+	term := testTerm()
+	dir := t.TempDir()
+	if err := WriteTermCSV(dir, term); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadTermCSV(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, term) {
+		t.Errorf("got %+v, expected %+v", read, term)
+	}
+}
+
+func TestTermCSVOldVersions(t *testing.T) {
+	// version 1 exports have no joint listings, and versions 1 and 2 no
+	// instructors
+	testSet := []struct {
+		version int
+		missing []string
+	}{
+		{1, []string{"classjoints.csv", "instructors.csv"}},
+		{2, []string{"instructors.csv"}},
+	}
+	for _, test := range testSet {
+		term := testTerm()
+		term.Version = test.version
+		if test.version < 2 {
+			term.ClassJoints = nil
+		}
+		term.Instructors = nil
+		dir := t.TempDir()
+		if err := WriteTermCSV(dir, term); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range test.missing {
+			if err := os.Remove(filepath.Join(dir, file)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		read, err := ReadTermCSV(dir)
+		if err != nil {
+			t.Errorf("version %d: %v", test.version, err)
+			continue
+		}
+		if !reflect.DeepEqual(read, term) {
+			t.Errorf("version %d: got %+v, expected %+v", test.version, read, term)
+		}
+	}
+}
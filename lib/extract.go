@@ -4,28 +4,12 @@ package goschedule
 
 import (
 	"encoding/json"
-	"encoding/xml"
 	"fmt"
-	"io"
-	"regexp"
 	"strconv"
 	"strings"
-)
-
-// htmlDecoder that creates an XML decoder that can handle typical HTML.
-// See http://golang.org/pkg/encoding/xml/#Decoder.
-func newHtmlDecoder(s string) *xml.Decoder {
-	decoder := xml.NewDecoder(strings.NewReader(s))
-	decoder.Strict = false
-	decoder.Entity = xml.HTMLEntity
-	decoder.AutoClose = xml.HTMLAutoClose
-	return decoder
-}
 
-// cleanHref surrounds the value of an href tag with quotes if it is unquoted.
-func cleanHref(s string) string {
-	return regexp.MustCompile(`(?i)href=(?:"|')?(.+)[.](html?)(?:"|')?`).ReplaceAllString(s, `href="$1.$2"`)
-}
+	"golang.org/x/net/html"
+)
 
 type errorsSlice []error
 
@@ -37,42 +21,30 @@ func (e errorsSlice) Error() string {
 	return errStrings
 }
 
-// ExtractColleges grabs College structs from a string
+// ExtractColleges grabs College structs from a string, a time schedule
+// department index. Colleges are listed by links to the section of the page
+// for each, separated by "|". A college's position runs from the heading of
+// its section to the end of the heading of the next section, or the end of
+// content. Links commented out of the list are checked too, and reported in
+// the error if their college has no section.
 func ExtractColleges(content string) ([]College, error) {
 	var colleges []College
 	var errs errorsSlice
-	// process hash links
-	for _, match := range collegeLinkRe.FindAllString(content, -1) {
-		var college College
-		// parse links from xml
-		tag := struct {
-			Href    string `xml:"href,attr"`
-			Content string `xml:",innerxml"`
-		}{}
-		decoder := newHtmlDecoder(match)
-		if err := decoder.Decode(&tag); err != nil && err != io.EOF {
-			errs = append(errs, fmt.Errorf("skipped a college: error unmarshalling xml (%s): %v", string(match), err))
+	tokens := tokenize(content)
+	headings := collegeHeadings(tokens)
+	for _, college := range collegeLinks(tokens) {
+		i := 0
+		for i < len(headings) && headings[i].name != college.Abbreviation {
+			i++
+		}
+		if i == len(headings) {
+			errs = append(errs, fmt.Errorf(`skipped college: could not find abbreviation in main body: "%s"`, college.Abbreviation))
 			continue
 		}
-		abbreviation := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag.Href), "#"))
-		// set attributes
-		college.Abbreviation = abbreviation
-		college.Name = tag.Content
-		// setup regex to get positions
-		collegeRe := regexp.MustCompile(fmt.Sprintf(`(?i)<a name="%s.+?</a>\n<h2>.+?</h2>((?s).*?)<a name=".+?</a>\n<h2>.+?</h2>`, abbreviation))
-		if position := collegeRe.FindStringIndex(content); position != nil {
-			college.Start = position[0]
-			college.End = position[1]
-		} else {
-			startPosition := regexp.MustCompile(
-				fmt.Sprintf(`(?i)<a name="%s.+?</a>\n<h2>.+?</h2>`, abbreviation)).
-				FindStringIndex(content) // will panic if doesn't find a match
-			if startPosition == nil {
-				errs = append(errs, fmt.Errorf(`skipped college: could not find abbreviation in main body: "%s"`, college.Abbreviation))
-				continue
-			}
-			college.Start = startPosition[0]
-			college.End = len(content)
+		college.Start = headings[i].Start
+		college.End = len(content)
+		if i+1 < len(headings) {
+			college.End = headings[i+1].End
 		}
 		colleges = append(colleges, college)
 	}
@@ -83,13 +55,78 @@ func ExtractColleges(content string) ([]College, error) {
 	}
 }
 
+// collegeLinks returns the colleges linked to by "#<abbreviation>" links
+// followed by "|" in tokens, without positions.
+func collegeLinks(tokens []token) []College {
+	var colleges []College
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].Type == html.CommentToken {
+			colleges = append(colleges, collegeLinks(tokenize(tokens[i].Data))...)
+			continue
+		}
+		href, _ := tokens[i].attr("href")
+		href = strings.TrimSpace(href)
+		if !tokens[i].isStart("a") || !strings.HasPrefix(href, "#") {
+			continue
+		}
+		name, end := textUntil(tokens, i+1, "a")
+		i = end
+		// the separator follows on the same line
+		if i+1 >= len(tokens) || tokens[i+1].Type != html.TextToken {
+			continue
+		}
+		if line := strings.SplitN(tokens[i+1].Data, "\n", 2)[0]; !strings.HasPrefix(strings.TrimSpace(line), "|") {
+			continue
+		}
+		colleges = append(colleges, College{
+			Name:         name,
+			Abbreviation: strings.ToLower(strings.TrimPrefix(href, "#")),
+		})
+	}
+	return colleges
+}
+
+// A collegeHeading is the heading of the section for a college on a time
+// schedule department index: a named anchor followed by an h2 element.
+type collegeHeading struct {
+	name       string // of the anchor, lowercase
+	Start, End int
+}
+
+// collegeHeadings returns the college headings in tokens.
+func collegeHeadings(tokens []token) []collegeHeading {
+	var headings []collegeHeading
+	for i := 0; i < len(tokens); i++ {
+		name, ok := tokens[i].attr("name")
+		if !tokens[i].isStart("a") || !ok {
+			continue
+		}
+		_, end := textUntil(tokens, i+1, "a")
+		h2 := skipSpace(tokens, end+1)
+		if h2 >= len(tokens) || !tokens[h2].isStart("h2") {
+			continue
+		}
+		title, end := textUntil(tokens, h2+1, "h2")
+		if end == len(tokens) || strings.TrimSpace(title) == "" {
+			continue
+		}
+		headings = append(headings, collegeHeading{
+			name:  strings.ToLower(strings.TrimSpace(name)),
+			Start: tokens[i].Start,
+			End:   tokens[end].End,
+		})
+		i = end
+	}
+	return headings
+}
+
 // Extract grabs Dept structs from a string.
 //
 // All Dept structs in the returned slice will use collegeKey as their collegeKey attribute.
 // processed is a map of Dept.Abbreviation's that have already been processed. The int values
 // are not used.
 // ExtractDepts will skip a Dept if its abbreviation is in processed. Else, it will add the
-// abbreviation to processed.
+// abbreviation to processed. A nil processed, or one pointing to a nil map, is treated as empty.
 //
 // Note that the department's abbreviation (primary key) cannot be scraped from the department index.
 // The abbreviation from the class listing by visiting the department page. Use
@@ -97,34 +134,36 @@ func ExtractColleges(content string) ([]College, error) {
 func ExtractDepts(content, collegeKey, url string, processed *map[string]int) ([]Dept, error) {
 	var depts []Dept
 	var errs errorsSlice
-	for _, match := range anchorRe.FindAllString(content, -1) {
-		// check validity
-		tag := struct {
-			Href    string `xml:"href,attr"`
-			Content string `xml:",innerxml"`
-		}{}
-		decoder := newHtmlDecoder(cleanHref(match))
-		if err := decoder.Decode(&tag); err != nil && err != io.EOF {
-			errs = append(errs, fmt.Errorf("skipped a department: error unmarshalling xml (%s): %v", string(match), err))
+	if processed == nil {
+		processed = new(map[string]int)
+	}
+	if *processed == nil {
+		*processed = make(map[string]int)
+	}
+	tokens := tokenize(content)
+	for i := range tokens {
+		if !tokens[i].isStart("a") {
 			continue
 		}
-		tag.Href = strings.TrimSpace(tag.Href)
-		tag.Content = strings.TrimSpace(tag.Content)
-		if valid := validateDept(tag.Href, tag.Content); !valid {
+		link, _ := tokens[i].attr("href")
+		link = strings.TrimSpace(link)
+		text, _ := textUntil(tokens, i+1, "a")
+		text = strings.TrimSpace(text)
+		if valid := validateDept(link, text); !valid {
 			continue
 		}
 		// create Dept
 		var dept Dept
 		// grab link
-		dept.Link = url + string(tag.Href)
+		dept.Link = url + link
 		// grab title
-		dept.Name = strings.TrimSpace(parenthesesRe.ReplaceAllString(tag.Content, ""))
+		dept.Name = strings.TrimSpace(parenthesesRe.ReplaceAllString(text, ""))
 		// grab href
 		var href string
-		if temp := strings.Split(tag.Href, "."); len(temp) > 0 {
+		if temp := strings.Split(link, "."); len(temp) > 0 {
 			href = temp[0]
 		} else {
-			errs = append(errs, fmt.Errorf(`skipped department: invalid href format: "%s"`, tag.Href))
+			errs = append(errs, fmt.Errorf(`skipped department: invalid href format: "%s"`, link))
 			continue
 		}
 		// check department for uniqueness
@@ -161,72 +200,85 @@ func validateDept(href, content string) bool {
 
 // ExtractClasses grabs Class structs from a string. All Class structs
 // in the returned slice will use deptKey as their DeptKey attribute.
+// Each class on a class index page starts with a table with a light blue
+// background, holding an anchor named by the class and a link titled with
+// its name.
 func ExtractClasses(content, deptKey string) []Class {
 	var classes []Class
-	matchIndices := classChunkRe.FindAllStringIndex(content, -1)
-	for _, match := range classChunkRe.FindAllString(content, -1) {
+	tokens := tokenize(content)
+	for i := range tokens {
+		if bgcolor, _ := tokens[i].attr("bgcolor"); !tokens[i].isStart("table") || !strings.EqualFold(bgcolor, "#99ccff") {
+			continue
+		}
 		var class Class
 		class.DeptKey = deptKey
-		// grab name (abbreviation and code)
-		name := classNameRe.FindString(match)
-		// grab abbreviation
-		class.Abbreviation = strings.ToLower(classAbbreviationRe.FindString(name))
-		// grab code
-		class.Code = strings.ToLower(classCodeRe.FindString(name))
-		// grab title
-		class.Name = strings.ToLower(tagRe.ReplaceAllString(classTitleRe.FindString(match), ""))
-		// set AbbreviationCode key
-		class.AbbreviationCode = class.Abbreviation + class.Code
-		// append to slice
+		class.Start = tokens[i].Start
+		for j := i + 1; j < len(tokens) && !tokens[j].isEnd("table"); j++ {
+			if !tokens[j].isStart("a") {
+				continue
+			}
+			if name, ok := tokens[j].attr("name"); ok && class.AbbreviationCode == "" {
+				// grab abbreviation and code, ex. "cse142"
+				class.Abbreviation = strings.ToLower(classAbbreviationRe.FindString(name))
+				class.Code = strings.ToLower(classCodeRe.FindString(name))
+				// set AbbreviationCode key
+				class.AbbreviationCode = class.Abbreviation + class.Code
+			}
+			if _, ok := tokens[j].attr("href"); ok && class.Name == "" {
+				// grab title
+				title, _ := textUntil(tokens, j+1, "a")
+				class.Name = strings.ToLower(title)
+			}
+		}
 		classes = append(classes, class)
 	}
 	// set class positions
-	for i := 0; i < len(classes); i++ {
-		classes[i].Start = matchIndices[i][0]
-		if i == len(classes)-1 {
-			classes[i].End = len(content)
-			break
+	for i := range classes {
+		classes[i].End = len(content)
+		if i+1 < len(classes) {
+			classes[i].End = classes[i+1].Start
 		}
-		classes[i].End = matchIndices[i+1][0]
 	}
 	return classes
 }
 
 // ExtractSects grabs Sect structs from a string. All Sect structs
 // in the returned slice will use classKey as their ClassKey attribute.
+// The column layout is detected from content with DetectLayout; use
+// ExtractSectsWithLayout when content is only part of a class index page.
 func ExtractSects(content, classKey string) ([]Sect, error) {
+	layout, _ := DetectLayout(content) // DefaultLayout on error
+	return ExtractSectsWithLayout(content, classKey, layout)
+}
+
+// ExtractSectsWithLayout is like ExtractSects, but parses section lines with
+// the given layout.
+//
+// Sections whose first line cannot be parsed are skipped. Errors for skipped
+// and partially parsed sections are returned as *LineError's.
+func ExtractSectsWithLayout(content, classKey string, layout Layout) ([]Sect, error) {
 	var sects []Sect
 	var errs errorsSlice
-	for _, match := range sectChunkRe.FindAllString(content, -1) {
-		var sect Sect
-		// remove html tags
-		match = tagRe.ReplaceAllString(match, "")
-		lines := strings.Split(match, "\n")
+	for _, listing := range sectListings(content) {
+		lines := strings.Split(listing, "\n")
+		// extract sect attributes from first line
+		sect, err := layout.parseSectLine(lines[0])
+		if err != nil {
+			errs = append(errs, err)
+			if sect.SLN == "" {
+				continue
+			}
+		}
 		var meetingTimes []MeetingTime
 		// check first line for meeting time
-		if mt, err := checkMeetingTime(lines[0]); err == nil {
+		if mt, ok := layout.parseMeetingTime(lines[0]); ok {
 			meetingTimes = append(meetingTimes, mt)
 		}
-		// extract sect attributes from rest of first line
-		sect.Restriction = strings.TrimSpace(lines[0][0:7])
-		sect.SLN = strings.ToLower(strings.TrimSpace(lines[0][7:13]))
-		sect.Section = strings.TrimSpace(lines[0][13:16])
-		sect.Credit = strings.TrimSpace(lines[0][16:24])
-		sect.Instructor = strings.TrimSpace(lines[0][56:83])
-		sect.Status = strings.TrimSpace(lines[0][83:89])
-		spots := strings.TrimSpace(lines[0][89:101])
-		if m := spotsRe.FindAllString(spots, -1); len(m) > 1 {
-			sect.TakenSpots, _ = strconv.ParseInt(m[0], 10, 64)
-			sect.TotalSpots, _ = strconv.ParseInt(m[1], 10, 64)
-		}
-		sect.Grades = strings.TrimSpace(lines[0][101:108])
-		sect.Fee = strings.TrimSpace(lines[0][108:115])
-		sect.Other = strings.TrimSpace(lines[0][115:])
 		// crawl through other lines
 		lines = lines[1:]
 		for _, line := range lines {
 			// check if MeetingTime
-			if mt, err := checkMeetingTime(line); err == nil {
+			if mt, ok := layout.parseMeetingTime(line); ok {
 				meetingTimes = append(meetingTimes, mt)
 			} else if blankLineRe.MatchString(line) {
 				// skip if blank line
@@ -245,6 +297,7 @@ func ExtractSects(content, classKey string) ([]Sect, error) {
 		sect.ClassKey = classKey
 		sects = append(sects, sect)
 	}
+	linkSects(sects)
 	if len(errs) < 1 {
 		return sects, nil
 	} else {
@@ -252,44 +305,94 @@ func ExtractSects(content, classKey string) ([]Sect, error) {
 	}
 }
 
-// checkMeetingTime checks if a string contains information for
-// a MeetingTime struct.
-// If a MeetingTime is found, it is returned with nil error. Else,
-// nil is returned for MeetingTime and non-nil for error.
-func checkMeetingTime(line string) (MeetingTime, error) {
-	var mt MeetingTime
-	if meetingTimeRe.FindString(line) != "" {
-		mt.Days = strings.TrimSpace(line[24:31])
-		mt.Time = strings.TrimSpace(line[31:42])
-		mt.Building = strings.TrimSpace(line[42:47])
-		mt.Room = strings.TrimSpace(line[47:56])
-		return mt, nil
+// sectListings returns the text of each section listing on a class index
+// page, with HTML tags removed. A listing starts with a link to the section
+// from its SLN, after the Restr column at the start of a line, and ends with
+// the table cell holding it. The text of a listing starts at the Restr
+// column, padded to its width.
+func sectListings(content string) []string {
+	var listings []string
+	var line string // text of the current line so far
+	var listing *strings.Builder
+	for _, t := range tokenize(content) {
+		switch {
+		case listing == nil && t.isStart("a"):
+			href, _ := t.attr("href")
+			restr := []rune(line)
+			if !strings.HasPrefix(strings.ToLower(href), "http") || len(restr) > DefaultLayout.starts[colSLN] {
+				continue
+			}
+			listing = new(strings.Builder)
+			listing.WriteString(strings.Repeat(" ", DefaultLayout.starts[colSLN]-len(restr)))
+			listing.WriteString(line)
+		case listing != nil && t.isEnd("td"):
+			listings = append(listings, listing.String())
+			listing = nil
+		case t.Type == html.TextToken:
+			if listing != nil {
+				listing.WriteString(t.Data)
+			}
+			if i := strings.LastIndex(t.Data, "\n"); i >= 0 {
+				line = t.Data[i+1:]
+			} else {
+				line += t.Data
+			}
+		}
+	}
+	return listings
+}
+
+// parseSpots parses the enrollment column of a section line, ex. "45/ 50",
+// into the number of taken and total spots.
+func parseSpots(spots string) (taken, total int64) {
+	if m := spotsRe.FindAllString(spots, -1); len(m) > 1 {
+		taken, _ = strconv.ParseInt(m[0], 10, 64)
+		total, _ = strconv.ParseInt(m[1], 10, 64)
+	}
+	return taken, total
+}
+
+// linkSects sets Sect.LectureKey of each quiz or lab section (ex. "AA") to
+// the SLN of its lecture, the section with the longest identifier that is a
+// prefix of its own (ex. "A").
+func linkSects(sects []Sect) {
+	for i := range sects {
+		var lecture string
+		for _, other := range sects {
+			if len(other.Section) < len(sects[i].Section) &&
+				len(other.Section) > len(lecture) &&
+				strings.HasPrefix(sects[i].Section, other.Section) {
+				lecture = other.Section
+				sects[i].LectureKey = other.SLN
+			}
+		}
 	}
-	return mt, fmt.Errorf("")
 }
 
 // ExtractClassDescriptionLinks grabs links from an index of links to class
 // description pages.
 func ExtractClassDescriptionLinks(content, root string) []string {
 	var links = []string{}
-	for _, match := range classDescriptionLinkRe.FindAllStringSubmatch(content, -1) {
-		links = append(links, root+match[1])
+	for _, t := range tokenize(content) {
+		if href, ok := t.attr("href"); t.isStart("a") && ok && classDescriptionLinkRe.MatchString(href) {
+			links = append(links, root+href)
+		}
 	}
 	return links
 }
 
 // ExtractClassDescriptions extracts class descriptions from content. It returns a
 // map of class abbreviationCode's (primary key) to class descriptions.
+// Use ExtractCatalogEntries for the structured parts of each description.
 func ExtractClassDescriptions(content string) (map[string]string, error) {
+	entries, err := ExtractCatalogEntries(content)
+	if err != nil {
+		return nil, err
+	}
 	descriptions := make(map[string]string)
-	for _, match := range classDescriptionRe.FindAllStringSubmatch(content, -1) {
-		if len(match) < 3 {
-			return nil, fmt.Errorf("less than 3 submatches found: %q", match)
-		}
-		// grab class AbbreviationCode
-		abbreviationCode := strings.ToLower(strings.TrimSpace(match[1]))
+	for _, entry := range entries {
 		// store abbreviationCode and description as key-value pair
-		descriptions[abbreviationCode] = strings.TrimSpace(match[2])
+		descriptions[entry.AbbreviationCode] = entry.Description
 	}
 	return descriptions, nil
 }
@@ -1,29 +1,190 @@
 package goschedule
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 )
 
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with the output of the extractors")
+
+// timeschdRoot is the link of the time schedule pages in testdata, used as
+// the root of department links.
+const timeschdRoot = "https://www.washington.edu/students/timeschd/AUT2013/"
+
+// goldenCases run the extractors on the pages in testdata, trimmed copies
+// of a time schedule department index, class index pages and a course
+// catalog page. The output of each is compared to
+// testdata/<page>.<name>.golden; run 'go test -run TestGolden -update' to
+// rewrite them after an intended change.
+var goldenCases = []struct {
+	page    string
+	name    string
+	extract func(content string) (interface{}, error)
+}{
+	{"timeschd_index.html", "colleges", func(content string) (interface{}, error) {
+		colleges, err := ExtractColleges(content)
+		var out []positioned
+		for _, college := range colleges {
+			out = append(out, positioned{college, college.Start, college.End})
+		}
+		return out, err
+	}},
+	{"timeschd_index.html", "depts", extractAllDepts},
+	{"timeschd_cse.html", "classes", func(content string) (interface{}, error) {
+		return extractPositionedClasses(content, "cse"), nil
+	}},
+	{"timeschd_cse.html", "sects", extractAllSects},
+	{"timeschd_math.html", "classes", func(content string) (interface{}, error) {
+		return extractPositionedClasses(content, "math"), nil
+	}},
+	{"timeschd_math.html", "sects", extractAllSects},
+	{"crscat_cse.html", "descriptions", func(content string) (interface{}, error) {
+		return ExtractClassDescriptions(content)
+	}},
+}
+
+// positioned is a college or class with its position in the page, which is
+// not marshalled with it.
+type positioned struct {
+	Value      interface{}
+	Start, End int
+}
+
+// extractPositionedClasses extracts the classes of a class index page with
+// their positions.
+func extractPositionedClasses(content, deptKey string) []positioned {
+	var out []positioned
+	for _, class := range ExtractClasses(content, deptKey) {
+		out = append(out, positioned{class, class.Start, class.End})
+	}
+	return out
+}
+
+// extractAllDepts extracts the departments of every college on a time
+// schedule department index, as the scraper does.
+func extractAllDepts(content string) (interface{}, error) {
+	var depts []Dept
+	var errs errorsSlice
+	colleges, err := ExtractColleges(content)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	processed := make(map[string]int)
+	for _, college := range colleges {
+		collegeDepts, err := ExtractDepts(content[college.Start:college.End], college.Abbreviation, timeschdRoot, &processed)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		depts = append(depts, collegeDepts...)
+	}
+	if len(errs) > 0 {
+		return depts, errs
+	}
+	return depts, nil
+}
+
+// extractAllSects extracts the sections of every class on a class index
+// page with the layout of the page, as the scraper does.
+func extractAllSects(content string) (interface{}, error) {
+	layout, _ := DetectLayout(content)
+	sects := make(map[string][]Sect)
+	var errs errorsSlice
+	for _, class := range ExtractClasses(content, "") {
+		classSects, err := ExtractSectsWithLayout(content[class.Start:class.End], class.AbbreviationCode, layout)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		sects[class.AbbreviationCode] = classSects
+	}
+	if len(errs) > 0 {
+		return sects, errs
+	}
+	return sects, nil
+}
+
+// goldenOutput is the content of a golden file.
+type goldenOutput struct {
+	Result interface{}
+	Errors []string `json:",omitempty"`
+}
+
+// errorStrings returns the errors in err, one per element of an
+// errorsSlice.
+func errorStrings(err error) []string {
+	if err == nil {
+		return nil
+	}
+	errs, ok := err.(errorsSlice)
+	if !ok {
+		return []string{err.Error()}
+	}
+	var s []string
+	for _, err := range errs {
+		s = append(s, errorStrings(err)...)
+	}
+	return s
+}
+
+func TestGolden(t *testing.T) {
+	for _, test := range goldenCases {
+		name := test.page[:len(test.page)-len(filepath.Ext(test.page))] + "." + test.name
+		t.Run(name, func(t *testing.T) {
+			raw, err := ioutil.ReadFile(filepath.Join("testdata", test.page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := test.extract(Filter(string(raw)))
+			got, jsonErr := json.MarshalIndent(goldenOutput{result, errorStrings(err)}, "", "\t")
+			if jsonErr != nil {
+				t.Fatal(jsonErr)
+			}
+			got = append(got, '\n')
+			path := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := ioutil.WriteFile(path, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("output differs from %s (run with -update to rewrite it):\n%s", path, got)
+			}
+		})
+	}
+}
+
 func TestExtractColleges(t *testing.T) {
 	content := `<a href="#AUP">Architecture and Urban Planning</a> |
-                <a href="#AS">Arts &amp; Sciences</a> |
-                <a href="#AUP">Built Environments</a> |
-                <a href="#B">Business School</a> |
-                <!-- <a href="#CCS">Center for Career Serv</a> | -->
-                <a href="#D">Dentistry</a> |
-                <a href="#ED">Education</a> |
+<a href="#AS">Arts &amp; Sciences</a> |
+<a href="#AUP">Built Environments</a> |
+<a href="#B">Business School</a> |
+<!-- <a href="#CCS">Center for Career Serv</a> | -->
+<a href="#D">Dentistry</a> |
+<a href="#ED">Education</a> |
 
 
-                <a name="ED"></a>
-                <h2>college name</h2>
-                some department content...
+<a name="ED"></a>
+<h2>college name</h2>
+some department content...
 
-                <a name="AS"></a>
-                <h2>college name</h2>
-                some department content...`
-	colleges, err := ExtractColleges(content, "")
-	if len(colleges) != 2 || err != nil {
-		t.Errorf("case: %q", content)
+<a name="AS"></a>
+<h2>college name</h2>
+some department content...`
+	colleges, err := ExtractColleges(content)
+	// AUP, B, CCS and D have no section on the page
+	if len(colleges) != 2 || err == nil {
+		t.Errorf("case: %q: got %+v, error %v", content, colleges, err)
+	}
+	if len(colleges) == 2 && (colleges[0].Abbreviation != "as" || colleges[1].Abbreviation != "ed") {
+		t.Errorf("got %+v", colleges)
 	}
 }
 
@@ -41,21 +202,31 @@ func TestExtractDepts(t *testing.T) {
             <a href="cse.html">CS (CSE)</a>
             <a href="#cse">CS (CSE)</a>
             <a href="math.html">Math (math)</a>
+            <a href="cse.html">Computer Science (CSE)</a>
             <a href="biol.html">Biology</a>`, 2, false,
 		},
 	}
 	for _, test := range testSet {
-		depts, err := ExtractDepts(test.content, "a college", "uw.edu/")
+		processed := make(map[string]int)
+		depts, err := ExtractDepts(test.content, "a college", "uw.edu/", &processed)
 		if (len(depts) != test.length) || ((err != nil) != test.err) {
 			t.Errorf("case: %q", test.content)
 		}
 	}
 }
 
-func TestValidateDept(t *testing.T) {
-	hrefs := map[string]int{
-		"math.html": 1,
+func TestExtractDeptsNilProcessed(t *testing.T) {
+	content := `<a href="cse.html">CS (CSE)</a><a href="cse.html">CS (CSE)</a>`
+	if depts, err := ExtractDepts(content, "a college", "uw.edu/", nil); len(depts) != 1 || err != nil {
+		t.Errorf("got %+v, error %v", depts, err)
 	}
+	var processed map[string]int
+	if depts, err := ExtractDepts(content, "a college", "uw.edu/", &processed); len(depts) != 1 || err != nil || processed["cse"] != 1 {
+		t.Errorf("got %+v, error %v, processed %v", depts, err, processed)
+	}
+}
+
+func TestValidateDept(t *testing.T) {
 	testSet := []struct {
 		href     string
 		content  string
@@ -65,11 +236,98 @@ func TestValidateDept(t *testing.T) {
 		{`cse.html`, `Computer Science and Engineering (Comp Sci) (CSE)`, true},
 		{`#CSE`, `Computer Science and Engineering (CSE)`, false},
 		{`cse.html`, `Computer Science and Engineering`, false},
-		{`math.html`, `Computer Science and Engineering (CSE)`, false},
+		{``, `Computer Science and Engineering (CSE)`, false},
 	}
 	for _, test := range testSet {
-		if validateDept(test.href, test.content, hrefs) != test.expected {
+		if validateDept(test.href, test.content) != test.expected {
 			t.Errorf("case %v", test)
 		}
 	}
 }
+
+func TestLinkSects(t *testing.T) {
+	sects := []Sect{
+		{SLN: "1", Section: "A"},
+		{SLN: "2", Section: "AA"},
+		{SLN: "3", Section: "AB"},
+		{SLN: "4", Section: "B"},
+		{SLN: "5", Section: "BA"},
+		{SLN: "6", Section: "CA"},
+	}
+	linkSects(sects)
+	expected := []string{"", "1", "1", "", "4", ""}
+	for i, sect := range sects {
+		if sect.LectureKey != expected[i] {
+			t.Errorf("section %s: got lecture %q, expected %q", sect.Section, sect.LectureKey, expected[i])
+		}
+	}
+}
+
+// addPages adds the pages in testdata matching pattern, filtered as the
+// scraper does, to the seed corpus of a fuzz target.
+func addPages(f *testing.F, pattern string) {
+	paths, err := filepath.Glob(filepath.Join("testdata", pattern))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(Filter(string(raw)))
+	}
+}
+
+// checkPosition fails t if start and end do not slice content, as the
+// scraper slices pages with the positions of colleges and classes.
+func checkPosition(t *testing.T, start, end int, content string) {
+	if start < 0 || start > end || end > len(content) {
+		t.Fatalf("position [%d:%d] out of range of %d bytes", start, end, len(content))
+	}
+}
+
+func FuzzExtractColleges(f *testing.F) {
+	addPages(f, "timeschd_index.html")
+	f.Add("<a href=\"#(\">Broken</a> |\n<a name=\"(\"></a>\n<h2>x</h2>")
+	f.Add("<a href=\"#\">Empty</a> |")
+	f.Fuzz(func(t *testing.T, content string) {
+		colleges, _ := ExtractColleges(content)
+		for _, college := range colleges {
+			checkPosition(t, college.Start, college.End, content)
+		}
+	})
+}
+
+func FuzzExtractDepts(f *testing.F) {
+	addPages(f, "timeschd_index.html")
+	f.Add(`<a href="cse.html">CS (CSE)</a><a href=.html>(x)</a><a href="#x">(x)</a>`)
+	f.Fuzz(func(t *testing.T, content string) {
+		processed := make(map[string]int)
+		ExtractDepts(content, "college", timeschdRoot, &processed)
+	})
+}
+
+func FuzzExtractClasses(f *testing.F) {
+	addPages(f, "timeschd_*.html")
+	f.Add(`<table bgcolor="#99ccff"></table>`)
+	f.Fuzz(func(t *testing.T, content string) {
+		for _, class := range ExtractClasses(content, "dept") {
+			checkPosition(t, class.Start, class.End, content)
+		}
+	})
+}
+
+func FuzzExtractClassDescriptionLinks(f *testing.F) {
+	f.Add(`<a href="cse.html">Computer Science and Engineering</a><a href=math.html>Mathematics</a>`)
+	f.Fuzz(func(t *testing.T, content string) {
+		ExtractClassDescriptionLinks(content, "https://www.washington.edu/students/crscat/")
+	})
+}
+
+func FuzzExtractClassDescriptions(f *testing.F) {
+	addPages(f, "crscat_*.html")
+	f.Fuzz(func(t *testing.T, content string) {
+		ExtractClassDescriptions(content)
+	})
+}
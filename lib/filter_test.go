@@ -0,0 +1,32 @@
+package goschedule
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFilter(t *testing.T) {
+	testSet := []struct {
+		in, out string
+	}{
+		{"Arts &amp; Sciences", "Arts & Sciences"},
+		{"caf\xe9", "caf?"},
+		{"\xff\xfe&lt;b&gt;", "??<b>"},
+		{"", ""},
+	}
+	for _, test := range testSet {
+		if out := Filter(test.in); out != test.out {
+			t.Errorf("Filter(%q) = %q, want %q", test.in, out, test.out)
+		}
+	}
+}
+
+func FuzzFilter(f *testing.F) {
+	addPages(f, "*.html")
+	f.Add("caf\xe9 &amp; &#x1F600; &#xD800; &bogus; \xff\xfe")
+	f.Fuzz(func(t *testing.T, in string) {
+		if out := Filter(in); !utf8.ValidString(out) {
+			t.Fatalf("Filter(%q) = %q, not valid UTF-8", in, out)
+		}
+	})
+}
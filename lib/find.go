@@ -0,0 +1,122 @@
+package goschedule
+
+import (
+	"strings"
+	"time"
+)
+
+// A SectFilter selects sections. The zero value matches every section.
+type SectFilter struct {
+	// Days, if set, are the only days a section may meet on.
+	Days []time.Weekday
+	// After and Before, if non-zero, bound the start and end of every
+	// meeting of a section, in minutes after midnight.
+	After  int
+	Before int
+	// Open selects sections with open spots.
+	Open bool
+	// Credit, if set, is the Sect.Credit a section must have (ex. "5", "QZ").
+	Credit string
+	// Credits, if non-zero, is a number of credits a section must be able to
+	// be taken for. Sections with "VAR" credits match any number.
+	Credits int64
+	// MaxFee, if non-zero, is the largest fee a section may have, in cents.
+	// NoFee selects sections without a fee.
+	MaxFee int64
+	NoFee  bool
+	// Require are flags a section must all have, Exclude flags it must have
+	// none of.
+	Require Flags
+	Exclude Flags
+	// Building, if set, is a building a section must meet in.
+	Building string
+}
+
+// hasSchedule reports whether f filters on meeting days or times.
+func (f SectFilter) hasSchedule() bool {
+	return len(f.Days) > 0 || f.After != 0 || f.Before != 0
+}
+
+// Match reports whether s is selected by f.
+//
+// A section without meeting times that can be parsed (ex. "to be arranged")
+// only matches a filter that does not set Days, After, Before or Building.
+func (f SectFilter) Match(s Sect) bool {
+	if f.Open && !s.IsOpen() {
+		return false
+	}
+	if f.Credit != "" && !strings.EqualFold(f.Credit, s.Credit) {
+		return false
+	}
+	if f.Credits != 0 && !(s.VariableCredits && s.MaxCredits == 0) && (f.Credits < s.MinCredits || f.Credits > s.MaxCredits) {
+		return false
+	}
+	if (f.NoFee && s.FeeCents != 0) || (f.MaxFee != 0 && s.FeeCents > f.MaxFee) {
+		return false
+	}
+	if !s.Flags.HasAll(f.Require) || s.Flags.HasAny(f.Exclude) {
+		return false
+	}
+	if !f.hasSchedule() && f.Building == "" {
+		return true
+	}
+	meetingTimes, err := s.GetMeetingTimes()
+	if err != nil {
+		return false
+	}
+	days := make(map[time.Weekday]bool)
+	for _, day := range f.Days {
+		days[day] = true
+	}
+	var scheduled, inBuilding bool
+	for _, mt := range meetingTimes {
+		if strings.EqualFold(mt.Building, f.Building) {
+			inBuilding = true
+		}
+		start, end, err := mt.Interval()
+		if err != nil {
+			continue
+		}
+		scheduled = true
+		if (f.After != 0 && start < f.After) || (f.Before != 0 && end > f.Before) {
+			return false
+		}
+		for _, day := range mt.Weekdays() {
+			if len(days) > 0 && !days[day] {
+				return false
+			}
+		}
+	}
+	if f.Building != "" && !inBuilding {
+		return false
+	}
+	return scheduled || !f.hasSchedule()
+}
+
+// FilterSects returns the sections in sects selected by f, in order.
+func FilterSects(sects []Sect, f SectFilter) []Sect {
+	var matches []Sect
+	for _, sect := range sects {
+		if f.Match(sect) {
+			matches = append(matches, sect)
+		}
+	}
+	return matches
+}
+
+// Paginate returns page number page (starting at 1) of sects, with perPage
+// sections per page, and the number of pages. A page out of range is empty.
+func Paginate(sects []Sect, page, perPage int) ([]Sect, int) {
+	if perPage < 1 {
+		perPage = 1
+	}
+	pages := (len(sects) + perPage - 1) / perPage
+	if page < 1 || page > pages {
+		return nil, pages
+	}
+	end := page * perPage
+	if end > len(sects) {
+		end = len(sects)
+	}
+	return sects[(page-1)*perPage : end], pages
+}
@@ -0,0 +1,55 @@
+package goschedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSectFilterMatch(t *testing.T) {
+	morning := Sect{SLN: "1", Credit: "5", MinCredits: 5, MaxCredits: 5, FeeCents: 5000, TotalSpots: 10, Other: "W", Flags: Flags(FlagWriting), MeetingTimes: `[{"Days":"MWF","Time":"930-1020","Building":"KNE","Room":"130"}]`}
+	evening := Sect{SLN: "2", Credit: "QZ", TakenSpots: 20, TotalSpots: 20, Restriction: "Restr", Grades: "CR/NC", Flags: Flags(FlagRestricted | FlagCreditNoCredit), MeetingTimes: `[{"Days":"TTh","Time":"630-820P","Building":"GUG","Room":"220"}]`}
+	arranged := Sect{SLN: "3", Credit: "VAR", VariableCredits: true, TotalSpots: 5, MeetingTimes: `[{"Days":"*","Time":"to be arranged","Building":"","Room":""}]`}
+	testSet := []struct {
+		filter   SectFilter
+		expected []bool
+	}{
+		{SectFilter{}, []bool{true, true, true}},
+		{SectFilter{Open: true}, []bool{true, false, true}},
+		{SectFilter{Days: []time.Weekday{time.Monday, time.Wednesday, time.Friday}}, []bool{true, false, false}},
+		{SectFilter{After: 12 * 60}, []bool{false, true, false}},
+		{SectFilter{Before: 12 * 60}, []bool{true, false, false}},
+		{SectFilter{Credit: "qz"}, []bool{false, true, false}},
+		{SectFilter{Require: Flags(FlagWriting)}, []bool{true, false, false}},
+		{SectFilter{Exclude: Flags(FlagRestricted | FlagCreditNoCredit)}, []bool{true, false, true}},
+		{SectFilter{Credits: 5}, []bool{true, false, true}},
+		{SectFilter{Credits: 3}, []bool{false, false, true}},
+		{SectFilter{NoFee: true}, []bool{false, true, true}},
+		{SectFilter{MaxFee: 4000}, []bool{false, true, true}},
+		{SectFilter{Building: "gug"}, []bool{false, true, false}},
+	}
+	for _, test := range testSet {
+		for i, sect := range []Sect{morning, evening, arranged} {
+			if match := test.filter.Match(sect); match != test.expected[i] {
+				t.Errorf("filter %+v, section %s: got %v", test.filter, sect.SLN, match)
+			}
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	sects := make([]Sect, 5)
+	testSet := []struct {
+		page, perPage, length, pages int
+	}{
+		{1, 2, 2, 3},
+		{3, 2, 1, 3},
+		{4, 2, 0, 3},
+		{0, 2, 0, 3},
+		{1, 10, 5, 1},
+	}
+	for _, test := range testSet {
+		if page, pages := Paginate(sects, test.page, test.perPage); len(page) != test.length || pages != test.pages {
+			t.Errorf("case %+v: got %d, %d", test, len(page), pages)
+		}
+	}
+}
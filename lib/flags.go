@@ -0,0 +1,155 @@
+package goschedule
+
+import (
+	"strings"
+)
+
+// A Flag is a symbol listed in the Restr, Grades or Other column of a section.
+type Flag int64
+
+// Flags of a section. The symbol used in the time schedule follows each.
+const (
+	FlagRestricted       Flag = 1 << iota // Restr
+	FlagIndependentStudy                  // IS
+	FlagAddCode                           // >
+	FlagCreditNoCredit                    // CR/NC
+	FlagDistanceLearning                  // D
+	FlagHonors                            // H
+	FlagJointlyOffered                    // J
+	FlagResearch                          // R
+	FlagServiceLearning                   // S
+	FlagWriting                           // W
+	FlagNewCourse                         // %
+	FlagNoFinancialAid                    // #
+)
+
+// flagInfo describes a Flag.
+type flagInfo struct {
+	flag        Flag
+	symbol      string
+	description string
+}
+
+// flagInfos lists every Flag in the order of the columns of a section line.
+var flagInfos = []flagInfo{
+	{FlagRestricted, "Restr", "Class, Major, or College requirements restrict registration into this section."},
+	{FlagIndependentStudy, "IS", "Independent Study: Faculty Code required."},
+	{FlagAddCode, ">", "Add Code required."},
+	{FlagCreditNoCredit, "CR/NC", "Credit/No-Credit grading."},
+	{FlagDistanceLearning, "D", "Distance learning (51% or more of the course instruction is through some mode of distance learning)."},
+	{FlagHonors, "H", "Honors section."},
+	{FlagJointlyOffered, "J", "Jointly offered course."},
+	{FlagResearch, "R", "Research."},
+	{FlagServiceLearning, "S", "Service learning."},
+	{FlagWriting, "W", "Writing section."},
+	{FlagNewCourse, "%", "New course."},
+	{FlagNoFinancialAid, "#", "Not eligible for some or all types of Financial Aid."},
+}
+
+// The flags that can appear in each column of a section line.
+var (
+	RestrictionFlags = []Flag{FlagRestricted, FlagIndependentStudy, FlagAddCode}
+	GradesFlags      = []Flag{FlagCreditNoCredit}
+	OtherFlags       = []Flag{FlagDistanceLearning, FlagHonors, FlagJointlyOffered, FlagResearch, FlagServiceLearning, FlagWriting, FlagNewCourse, FlagNoFinancialAid}
+)
+
+func (f Flag) info() flagInfo {
+	for _, info := range flagInfos {
+		if info.flag == f {
+			return info
+		}
+	}
+	return flagInfo{flag: f}
+}
+
+// Symbol returns the symbol used for f in the time schedule, ex. "H".
+func (f Flag) Symbol() string {
+	return f.info().symbol
+}
+
+// Description returns a human-readable description of f.
+func (f Flag) Description() string {
+	return f.info().description
+}
+
+// String implements fmt.Stringer for Flag.
+func (f Flag) String() string {
+	return f.Symbol()
+}
+
+// ParseFlag returns the Flag with the given symbol. ok is false if there
+// is no such Flag.
+func ParseFlag(symbol string) (flag Flag, ok bool) {
+	for _, info := range flagInfos {
+		if info.symbol == symbol {
+			return info.flag, true
+		}
+	}
+	return 0, false
+}
+
+// Flags is a set of Flag's.
+type Flags int64
+
+// ParseFlags returns the flags listed in the Restr, Grades and Other columns
+// of a section line.
+func ParseFlags(restriction, grades, other string) Flags {
+	var flags Flags
+	for _, column := range []struct {
+		value string
+		flags []Flag
+	}{
+		{restriction, RestrictionFlags},
+		{grades, GradesFlags},
+		{other, OtherFlags},
+	} {
+		for _, flag := range column.flags {
+			if strings.Contains(column.value, flag.Symbol()) {
+				flags |= Flags(flag)
+			}
+		}
+	}
+	return flags
+}
+
+// Has reports whether flag is in f.
+func (f Flags) Has(flag Flag) bool {
+	return f&Flags(flag) != 0
+}
+
+// HasAll reports whether every flag in other is in f.
+func (f Flags) HasAll(other Flags) bool {
+	return f&other == other
+}
+
+// HasAny reports whether any flag in other is in f.
+func (f Flags) HasAny(other Flags) bool {
+	return f&other != 0
+}
+
+// List returns the flags in f, in the order of the columns of a section line.
+func (f Flags) List() []Flag {
+	var list []Flag
+	for _, info := range flagInfos {
+		if f.Has(info.flag) {
+			list = append(list, info.flag)
+		}
+	}
+	return list
+}
+
+// A FlagState is a Flag and whether it is set, for listing every flag a
+// column can have.
+type FlagState struct {
+	Flag
+	Set bool
+}
+
+// States returns the state in f of each of flags.
+func (f Flags) States(flags []Flag) []FlagState {
+	states := make([]FlagState, len(flags))
+	for i, flag := range flags {
+		states[i] = FlagState{flag, f.Has(flag)}
+	}
+	return states
+}
@@ -0,0 +1,51 @@
+package goschedule
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	testSet := []struct {
+		restriction, grades, other string
+		expected                   []Flag
+	}{
+		{"", "", "", nil},
+		{"Restr", "", "", []Flag{FlagRestricted}},
+		{"IS >", "CR/NC", "", []Flag{FlagIndependentStudy, FlagAddCode, FlagCreditNoCredit}},
+		{"", "", "H W %", []Flag{FlagHonors, FlagWriting, FlagNewCourse}},
+		{"", "", "DJ#", []Flag{FlagDistanceLearning, FlagJointlyOffered, FlagNoFinancialAid}},
+	}
+	for _, test := range testSet {
+		flags := ParseFlags(test.restriction, test.grades, test.other)
+		if list := flags.List(); !reflect.DeepEqual(list, test.expected) {
+			t.Errorf("ParseFlags(%q, %q, %q) = %v, expected %v", test.restriction, test.grades, test.other, list, test.expected)
+		}
+	}
+}
+
+func TestFlagSymbols(t *testing.T) {
+	for _, info := range flagInfos {
+		if info.description == "" {
+			t.Errorf("flag %s has no description", info.symbol)
+		}
+		if flag, ok := ParseFlag(info.symbol); !ok || flag != info.flag {
+			t.Errorf("ParseFlag(%q) = %v, %v", info.symbol, flag, ok)
+		}
+	}
+	if _, ok := ParseFlag("X"); ok {
+		t.Errorf("ParseFlag(%q) succeeded", "X")
+	}
+}
+
+func TestFlagStates(t *testing.T) {
+	flags := Flags(FlagWriting | FlagHonors)
+	for _, state := range flags.States(OtherFlags) {
+		if state.Set != (state.Flag == FlagWriting || state.Flag == FlagHonors) {
+			t.Errorf("state of %s: got %v", state.Symbol(), state.Set)
+		}
+	}
+	if !flags.HasAll(Flags(FlagWriting)) || flags.HasAll(Flags(FlagWriting|FlagResearch)) || flags.HasAny(Flags(FlagResearch)) {
+		t.Errorf("Flags %v: HasAll/HasAny mismatch", flags.List())
+	}
+}
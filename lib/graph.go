@@ -0,0 +1,188 @@
+package goschedule
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// A PrereqGraph is a directed graph of classes, with an edge from each class
+// to every class named in its prerequisites.
+// Classes are identified by their abbreviation code (Class.AbbreviationCode).
+type PrereqGraph struct {
+	prereqs map[string]*Prereq
+	unlocks map[string][]string
+}
+
+// NewPrereqGraph builds a PrereqGraph from the prerequisites of classes.
+// Returns an error if a Class has a malformed Prerequisites field.
+func NewPrereqGraph(classes []Class) (*PrereqGraph, error) {
+	g := &PrereqGraph{
+		prereqs: make(map[string]*Prereq),
+		unlocks: make(map[string][]string),
+	}
+	for _, class := range classes {
+		prereq, err := class.GetPrerequisites()
+		if err != nil {
+			return nil, fmt.Errorf("bad prerequisites for %q: %v", class.AbbreviationCode, err)
+		}
+		g.Add(class.AbbreviationCode, prereq)
+	}
+	return g, nil
+}
+
+// Add adds a class and its prerequisites to g. A nil prereq is ignored.
+func (g *PrereqGraph) Add(class string, prereq *Prereq) {
+	if prereq == nil {
+		return
+	}
+	g.prereqs[class] = prereq
+	for _, course := range prereq.Courses() {
+		g.unlocks[course] = append(g.unlocks[course], class)
+	}
+}
+
+// Prereq returns the prerequisites of class, or nil if it has none.
+func (g *PrereqGraph) Prereq(class string) *Prereq {
+	return g.prereqs[class]
+}
+
+// Unlocks returns the classes that name class in their prerequisites, sorted.
+func (g *PrereqGraph) Unlocks(class string) []string {
+	unlocks := append([]string(nil), g.unlocks[class]...)
+	sort.Strings(unlocks)
+	return unlocks
+}
+
+// Chain returns every class that class depends on, directly or through other
+// prerequisites, nearest first. Each class appears once.
+func (g *PrereqGraph) Chain(class string) []string {
+	var chain []string
+	seen := map[string]bool{class: true}
+	queue := []string{class}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, course := range g.prereqs[current].Courses() {
+			if seen[course] {
+				continue
+			}
+			seen[course] = true
+			chain = append(chain, course)
+			queue = append(queue, course)
+		}
+	}
+	return chain
+}
+
+// Eligible returns the classes, sorted, whose prerequisites are satisfied by
+// the completed classes and that name at least one completed class.
+// Classes in completed are not returned. See Prereq.Satisfied.
+func (g *PrereqGraph) Eligible(completed []string) []string {
+	done := make(map[string]bool)
+	for _, class := range completed {
+		done[class] = true
+	}
+	candidates := make(map[string]bool)
+	for _, class := range completed {
+		for _, unlocked := range g.unlocks[class] {
+			candidates[unlocked] = true
+		}
+	}
+	var eligible []string
+	for class := range candidates {
+		if !done[class] && g.prereqs[class].Satisfied(done) {
+			eligible = append(eligible, class)
+		}
+	}
+	sort.Strings(eligible)
+	return eligible
+}
+
+// DOT returns the prerequisite chain of class and the classes it unlocks as a
+// Graphviz DOT digraph. Edges point from a prerequisite to the class that
+// needs it; edges to alternatives (one of several classes joined by "or")
+// are dashed.
+func (g *PrereqGraph) DOT(class string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "digraph %q {\n", class)
+	buf.WriteString("\trankdir=LR;\n")
+	fmt.Fprintf(&buf, "\t%q [style=filled, fillcolor=\"#FCEB95\"];\n", class)
+	for _, node := range append([]string{class}, g.Chain(class)...) {
+		g.writeEdges(&buf, node)
+	}
+	for _, unlocked := range g.Unlocks(class) {
+		g.writeEdges(&buf, unlocked)
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// writeEdges writes the DOT edges from each prerequisite of class to class.
+func (g *PrereqGraph) writeEdges(buf *bytes.Buffer, class string) {
+	optional := g.prereqs[class].alternatives()
+	for _, course := range g.prereqs[class].Courses() {
+		if optional[course] {
+			fmt.Fprintf(buf, "\t%q -> %q [style=dashed];\n", course, class)
+		} else {
+			fmt.Fprintf(buf, "\t%q -> %q;\n", course, class)
+		}
+	}
+}
+
+// Satisfied reports whether the completed classes satisfy p. A nil Prereq is
+// always satisfied.
+//
+// A leaf naming no class (ex. "permission of instructor") cannot be checked,
+// so it is ignored: it counts as satisfied within an "and" and unsatisfied
+// within an "or".
+func (p *Prereq) Satisfied(completed map[string]bool) bool {
+	return p.satisfied(completed, true)
+}
+
+// satisfied evaluates p, using neutral as the value of leaves naming no class.
+func (p *Prereq) satisfied(completed map[string]bool, neutral bool) bool {
+	if p == nil {
+		return true
+	}
+	switch p.Op {
+	case PrereqAnd:
+		for _, arg := range p.Args {
+			if !arg.satisfied(completed, true) {
+				return false
+			}
+		}
+		return true
+	case PrereqOr:
+		for _, arg := range p.Args {
+			if arg.satisfied(completed, false) {
+				return true
+			}
+		}
+		return false
+	}
+	if p.Course == "" {
+		return neutral
+	}
+	return completed[p.Course]
+}
+
+// alternatives returns the classes in p that are one of several choices
+// joined by "or".
+func (p *Prereq) alternatives() map[string]bool {
+	optional := make(map[string]bool)
+	var walk func(*Prereq, bool)
+	walk = func(p *Prereq, inOr bool) {
+		if p == nil {
+			return
+		}
+		if p.Course != "" && inOr {
+			optional[p.Course] = true
+		}
+		for _, arg := range p.Args {
+			walk(arg, inOr || p.Op == PrereqOr)
+		}
+	}
+	walk(p, false)
+	return optional
+}
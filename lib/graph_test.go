@@ -0,0 +1,58 @@
+package goschedule
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testPrereqGraph() *PrereqGraph {
+	g, _ := NewPrereqGraph(nil)
+	g.Add("cse143", ParsePrereq("CSE 142"))
+	g.Add("cse311", ParsePrereq("CSE 143; either MATH 126 or MATH 136"))
+	g.Add("cse332", ParsePrereq("CSE 311"))
+	g.Add("cse351", ParsePrereq("CSE 143 or permission of instructor"))
+	return g
+}
+
+func TestPrereqGraphChain(t *testing.T) {
+	g := testPrereqGraph()
+	if chain := g.Chain("cse332"); !reflect.DeepEqual(chain, []string{"cse311", "cse143", "math126", "math136", "cse142"}) {
+		t.Errorf("got %v", chain)
+	}
+	if chain := g.Chain("cse142"); chain != nil {
+		t.Errorf("got %v", chain)
+	}
+}
+
+func TestPrereqGraphEligible(t *testing.T) {
+	g := testPrereqGraph()
+	testSet := []struct {
+		completed []string
+		expected  []string
+	}{
+		{nil, nil},
+		{[]string{"cse142"}, []string{"cse143"}},
+		{[]string{"cse142", "cse143"}, []string{"cse351"}},
+		{[]string{"cse142", "cse143", "math136"}, []string{"cse311", "cse351"}},
+	}
+	for _, test := range testSet {
+		if eligible := g.Eligible(test.completed); !reflect.DeepEqual(eligible, test.expected) {
+			t.Errorf("case %v: got %v", test.completed, eligible)
+		}
+	}
+}
+
+func TestPrereqGraphDOT(t *testing.T) {
+	dot := testPrereqGraph().DOT("cse311")
+	for _, edge := range []string{
+		`"cse143" -> "cse311";`,
+		`"math126" -> "cse311" [style=dashed];`,
+		`"cse142" -> "cse143";`,
+		`"cse311" -> "cse332";`,
+	} {
+		if !strings.Contains(dot, edge) {
+			t.Errorf("missing edge %s in:\n%s", edge, dot)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package goschedule
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// A token is an HTML token of a page with the byte offsets of its raw text
+// in the page.
+type token struct {
+	html.Token
+	Start int
+	End   int
+}
+
+// tokenize splits content into HTML tokens. The tokens cover content without
+// gaps, so their offsets can be used to slice it.
+func tokenize(content string) []token {
+	var tokens []token
+	z := html.NewTokenizer(strings.NewReader(content))
+	offset := 0
+	for z.Next() != html.ErrorToken {
+		end := offset + len(z.Raw())
+		tokens = append(tokens, token{z.Token(), offset, end})
+		offset = end
+	}
+	return tokens
+}
+
+// isStart reports whether t starts an element named tag, a lowercase name
+// such as "a".
+func (t token) isStart(tag string) bool {
+	return (t.Type == html.StartTagToken || t.Type == html.SelfClosingTagToken) && t.Data == tag
+}
+
+// isEnd reports whether t ends an element named tag.
+func (t token) isEnd(tag string) bool {
+	return t.Type == html.EndTagToken && t.Data == tag
+}
+
+// isSpace reports whether t is text of only white space.
+func (t token) isSpace() bool {
+	return t.Type == html.TextToken && strings.TrimSpace(t.Data) == ""
+}
+
+// attr returns the value of attribute key of t, and whether it is set.
+func (t token) attr(key string) (string, bool) {
+	for _, a := range t.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// skipSpace returns the index of the first token of tokens from i that is
+// not white space text.
+func skipSpace(tokens []token, i int) int {
+	for i < len(tokens) && tokens[i].isSpace() {
+		i++
+	}
+	return i
+}
+
+// textUntil returns the text of tokens from i up to the first end tag of
+// tag, and the index of that end tag, or len(tokens) if there is none.
+func textUntil(tokens []token, i int, tag string) (string, int) {
+	var text strings.Builder
+	for ; i < len(tokens) && !tokens[i].isEnd(tag); i++ {
+		if tokens[i].Type == html.TextToken {
+			text.WriteString(tokens[i].Data)
+		}
+	}
+	return text.String(), i
+}
+
+// htmlText returns the text of the HTML fragment s, with each tag replaced
+// by sep.
+func htmlText(s, sep string) string {
+	var text strings.Builder
+	for _, t := range tokenize(s) {
+		switch t.Type {
+		case html.TextToken:
+			text.WriteString(t.Data)
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			text.WriteString(sep)
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,71 @@
+package goschedule
+
+import (
+	"strings"
+)
+
+// An Instructor teaches one or more sections. Sect.InstructorKey refers to
+// Instructor.Key.
+type Instructor struct {
+	Key   string `pk:"true"`
+	Name  string
+	Last  string
+	First string
+}
+
+// placeholderInstructors are listed instead of the name of an instructor not
+// yet assigned.
+var placeholderInstructors = map[string]bool{"STAFF": true, "TBA": true}
+
+// ParseInstructor parses an instructor name in the time schedule's
+// "LAST,FIRST M" format, such as Sect.Instructor. ok is false if name is blank
+// or a placeholder for an instructor not yet assigned, "STAFF" or "TBA".
+//
+// The Key is built from the last and first names only, ex. "reges-stuart",
+// since the middle initial is not always listed.
+func ParseInstructor(name string) (instructor Instructor, ok bool) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || placeholderInstructors[strings.ToUpper(name)] {
+		return instructor, false
+	}
+	var given string
+	if i := strings.Index(name, ","); i >= 0 {
+		instructor.Last = titleCase(name[:i])
+		given = titleCase(name[i+1:])
+	} else {
+		instructor.Last = titleCase(name)
+	}
+	if fields := strings.Fields(given); len(fields) > 0 {
+		instructor.First = fields[0]
+	}
+	instructor.Name = strings.TrimSpace(given + " " + instructor.Last)
+	instructor.Key = InstructorKey(instructor.Last, instructor.First)
+	return instructor, true
+}
+
+// InstructorKey returns the key of an instructor with the given last and first
+// names: both lowercased, with runs of other characters than letters and
+// digits replaced by "-".
+func InstructorKey(last, first string) string {
+	var key []rune
+	for _, r := range strings.ToLower(last + " " + first) {
+		if ('a' <= r && r <= 'z') || ('0' <= r && r <= '9') {
+			key = append(key, r)
+		} else if len(key) > 0 && key[len(key)-1] != '-' {
+			key = append(key, '-')
+		}
+	}
+	return strings.TrimSuffix(string(key), "-")
+}
+
+// titleCase capitalizes the first letter of each word of s (split on spaces
+// and hyphens) and lowercases the rest.
+func titleCase(s string) string {
+	runes := []rune(strings.ToLower(strings.TrimSpace(s)))
+	for i, r := range runes {
+		if i == 0 || runes[i-1] == ' ' || runes[i-1] == '-' {
+			runes[i] = []rune(strings.ToUpper(string(r)))[0]
+		}
+	}
+	return string(runes)
+}
@@ -0,0 +1,26 @@
+package goschedule
+
+import (
+	"testing"
+)
+
+func TestParseInstructor(t *testing.T) {
+	testSet := []struct {
+		in       string
+		expected Instructor
+		ok       bool
+	}{
+		{`REGES,STUART T`, Instructor{"reges-stuart", "Stuart T Reges", "Reges", "Stuart"}, true},
+		{`Reges,Stuart`, Instructor{"reges-stuart", "Stuart Reges", "Reges", "Stuart"}, true},
+		{`MCDONALD-SMITH,  ALEXANDRA`, Instructor{"mcdonald-smith-alexandra", "Alexandra Mcdonald-Smith", "Mcdonald-Smith", "Alexandra"}, true},
+		{`STAFF`, Instructor{}, false},
+		{` tba `, Instructor{}, false},
+		{`STAFFORD,JO`, Instructor{"stafford-jo", "Jo Stafford", "Stafford", "Jo"}, true},
+		{`  `, Instructor{}, false},
+	}
+	for _, test := range testSet {
+		if instructor, ok := ParseInstructor(test.in); instructor != test.expected || ok != test.ok {
+			t.Errorf("case %q: got %+v, %v", test.in, instructor, ok)
+		}
+	}
+}
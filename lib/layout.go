@@ -0,0 +1,246 @@
+package goschedule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Columns of a section line on a class index page, in order.
+const (
+	colRestr = iota
+	colSLN
+	colSection
+	colCredit
+	colDays
+	colTime
+	colBuilding
+	colRoom
+	colInstructor
+	colStatus
+	colSpots
+	colGrades
+	colFee
+	colOther
+	numColumns
+)
+
+// columnNames are the names of the columns used in a LineError.
+var columnNames = [numColumns]string{
+	"Restr", "SLN", "Section", "Credit", "Days", "Time", "Building", "Room",
+	"Instructor", "Status", "Spots", "Grades", "Fee", "Other",
+}
+
+// headerLabels maps the labels of the header row on a class index page to the
+// column each starts.
+var headerLabels = map[string]int{
+	"Restr":      colRestr,
+	"SLN":        colSLN,
+	"ID":         colSection,
+	"Sect":       colSection,
+	"Cred":       colCredit,
+	"Crd":        colCredit,
+	"Days":       colDays,
+	"Meeting":    colDays,
+	"Time":       colTime,
+	"Bldg":       colBuilding,
+	"Bldg/Rm":    colBuilding,
+	"Instructor": colInstructor,
+	"Status":     colStatus,
+	"Enrl":       colSpots,
+	"Enrl/Lim":   colSpots,
+	"Grades":     colGrades,
+	"Fee":        colFee,
+	"Other":      colOther,
+}
+
+// Errors returned by DetectLayout and wrapped by LineError.
+var (
+	ErrNoHeader      = errors.New("no header row found")
+	ErrBadLayout     = errors.New("header row columns out of order")
+	ErrShortLine     = errors.New("line ends before column")
+	ErrBadField      = errors.New("malformed field")
+	ErrLayoutInvalid = errors.New("layout does not match section lines")
+)
+
+// A Layout holds the byte offsets where each column of a section line starts,
+// after HTML tags are removed. Offsets are relative to the start of the
+// Restr column, which is always 7 bytes before the SLN.
+type Layout struct {
+	starts [numColumns]int
+}
+
+// DefaultLayout is the layout of class index pages as of autumn 2013.
+var DefaultLayout = Layout{[numColumns]int{0, 7, 13, 16, 24, 31, 42, 47, 56, 83, 89, 101, 108, 115}}
+
+// A LineError describes a section line that could not be fully parsed.
+type LineError struct {
+	Line   string
+	Column string
+	Err    error
+}
+
+// Error implements the error interface for LineError.
+func (e *LineError) Error() string {
+	return fmt.Sprintf("%s: %v: %q", e.Column, e.Err, e.Line)
+}
+
+// Unwrap returns the underlying error, such as ErrShortLine.
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// DetectLayout derives the Layout of content (a class index page) from the
+// labels of its header row. A column without a label keeps its offset from the
+// nearest labeled column before it, as in DefaultLayout. The header row may be
+// indented differently from section lines, since offsets are aligned on the SLN.
+//
+// If no usable header row is found, or the derived layout does not fit the
+// first section line in content (a numeric SLN and a one word Status),
+// DefaultLayout is returned with an error.
+func DetectLayout(content string) (Layout, error) {
+	header, found := findHeaderRow(content)
+	if !found {
+		return DefaultLayout, ErrNoHeader
+	}
+	var labeled [numColumns]bool
+	var positions [numColumns]int
+	for _, index := range wordRe.FindAllStringIndex(header, -1) {
+		col, ok := headerLabels[header[index[0]:index[1]]]
+		if !ok || labeled[col] {
+			continue
+		}
+		labeled[col] = true
+		positions[col] = index[0]
+	}
+	var layout Layout
+	delta := 0
+	for col := 0; col < numColumns; col++ {
+		if labeled[col] {
+			delta = positions[col] - DefaultLayout.starts[col]
+		}
+		layout.starts[col] = DefaultLayout.starts[col] + delta
+	}
+	// section listings start at the Restr column, a fixed distance before the SLN
+	shift := DefaultLayout.starts[colSLN] - layout.starts[colSLN]
+	layout.starts[colRestr] = -shift
+	for col := 0; col < numColumns; col++ {
+		layout.starts[col] += shift
+		if layout.starts[col] < 0 || (col > 0 && layout.starts[col] <= layout.starts[col-1]) {
+			return DefaultLayout, ErrBadLayout
+		}
+	}
+	if listings := sectListings(content); len(listings) > 0 {
+		line := strings.Split(listings[0], "\n")[0]
+		if sect, err := layout.parseSectLine(line); errors.Is(err, ErrBadField) || !isLetters(sect.Status) {
+			return DefaultLayout, ErrLayoutInvalid
+		}
+	}
+	return layout, nil
+}
+
+// findHeaderRow returns the first line of content, with HTML tags removed,
+// that labels the SLN and Instructor columns.
+func findHeaderRow(content string) (string, bool) {
+	for _, line := range strings.Split(htmlText(content, ""), "\n") {
+		if !strings.Contains(line, "SLN") || !strings.Contains(line, "Instructor") {
+			continue
+		}
+		return line, true
+	}
+	return "", false
+}
+
+// field returns column col of line with surrounding space trimmed. ok is
+// false if the line ends before the column starts.
+func (l Layout) field(line string, col int) (value string, ok bool) {
+	start := l.starts[col]
+	if start >= len(line) {
+		return "", false
+	}
+	end := len(line)
+	if col+1 < numColumns && l.starts[col+1] < end {
+		end = l.starts[col+1]
+	}
+	return strings.TrimSpace(line[start:end]), true
+}
+
+// parseSectLine parses the first line of a section listing (HTML tags
+// removed) into a Sect. The first meeting time on the line is not parsed;
+// see parseMeetingTime.
+//
+// A line without a numeric SLN returns a *LineError wrapping ErrBadField or
+// ErrShortLine. A line that ends before the Status column returns the fields
+// found so far with a *LineError wrapping ErrShortLine.
+func (l Layout) parseSectLine(line string) (Sect, error) {
+	var sect Sect
+	sln, ok := l.field(line, colSLN)
+	if !ok {
+		return sect, &LineError{line, columnNames[colSLN], ErrShortLine}
+	}
+	if !isDigits(sln) {
+		return sect, &LineError{line, columnNames[colSLN], ErrBadField}
+	}
+	sect.SLN = strings.ToLower(sln)
+	sect.Restriction, _ = l.field(line, colRestr)
+	sect.Section, _ = l.field(line, colSection)
+	sect.Credit, _ = l.field(line, colCredit)
+	sect.Instructor, _ = l.field(line, colInstructor)
+	if instructor, ok := ParseInstructor(sect.Instructor); ok {
+		sect.InstructorKey = instructor.Key
+	}
+	status, ok := l.field(line, colStatus)
+	if !ok {
+		return sect, &LineError{line, columnNames[colStatus], ErrShortLine}
+	}
+	sect.Status = status
+	spots, _ := l.field(line, colSpots)
+	sect.TakenSpots, sect.TotalSpots = parseSpots(spots)
+	sect.Grades, _ = l.field(line, colGrades)
+	sect.Fee, _ = l.field(line, colFee)
+	if start := l.starts[colOther]; start < len(line) {
+		sect.Other = strings.TrimSpace(line[start:])
+	}
+	sect.Flags = ParseFlags(sect.Restriction, sect.Grades, sect.Other)
+	sect.FeeCents, _ = ParseFee(sect.Fee)
+	credit := ParseSectCredit(sect.Credit)
+	sect.MinCredits, sect.MaxCredits = credit.Min, credit.Max
+	sect.VariableCredits, sect.Quiz = credit.Variable, credit.Quiz
+	return sect, nil
+}
+
+// parseMeetingTime checks if line contains a meeting time and parses it.
+// ok is false if line has no meeting time.
+func (l Layout) parseMeetingTime(line string) (mt MeetingTime, ok bool) {
+	if meetingTimeRe.FindString(line) == "" {
+		return mt, false
+	}
+	mt.Days, _ = l.field(line, colDays)
+	mt.Time, _ = l.field(line, colTime)
+	mt.Building, _ = l.field(line, colBuilding)
+	mt.Room, _ = l.field(line, colRoom)
+	return mt, true
+}
+
+// isLetters reports whether s contains only ASCII letters.
+func isLetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package goschedule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// defaultWidths are the column widths of DefaultLayout, except Other.
+var defaultWidths = []int{7, 6, 3, 8, 7, 11, 5, 9, 27, 6, 12, 7, 7}
+
+// formatRow pads each value to its column width.
+func formatRow(widths []int, values ...string) string {
+	var row string
+	for i, value := range values {
+		if i < len(widths) {
+			value = fmt.Sprintf("%-*s", widths[i], value)
+		}
+		row += value
+	}
+	return row
+}
+
+// headerRow formats a header row with the given column widths.
+func headerRow(widths []int) string {
+	return formatRow(widths, "Restr", "SLN", "ID", "Crd", "Days", "Time", "Bldg", "Room", "Instructor", "Status", "Enrl/Lim", "Grades", "Fee", "Other")
+}
+
+// sectLine formats the first line of a section listing with the given column
+// widths, ending with "</td>" unless the section has more lines.
+func sectLine(widths []int, restr, sln, section, instructor, status, spots string) string {
+	anchor := fmt.Sprintf("<A HREF=https://sdb.admin.washington.edu/timeschd/uwnetid/sln.asp?SLN=%s>%s</A>", sln, sln)
+	row := formatRow(widths, restr, sln, section, "5", "MWF", "1030-1120", "KNE", "130", instructor, status, spots, "CR/NC", "$50", "W")
+	return row[:widths[0]] + anchor + row[widths[0]+len(sln):]
+}
+
+func TestExtractSectsWithLayout(t *testing.T) {
+	content := sectLine(defaultWidths, "Restr", "12345", "A", "Reges,Stuart T", "open", " 400/ 450") + "\n" +
+		strings.Repeat(" ", 24) + "TTh    1130-1220  EEB  105\n" +
+		"Freshmen only</td>\n" +
+		sectLine(defaultWidths, "", "12346", "AA", "", "closed", "  20/  20") + "</td>\n" +
+		"       <A HREF=http://x>12347</A> AB   QZ</td>\n" +
+		"       <A HREF=http://x>xyz</A></td>\n"
+	sects, err := ExtractSectsWithLayout(content, "cse142", DefaultLayout)
+	if len(sects) != 3 {
+		t.Fatalf("got %d sects: %+v", len(sects), sects)
+	}
+	first := sects[0]
+	if first.SLN != "12345" || first.Section != "A" || first.Instructor != "Reges,Stuart T" || first.TakenSpots != 400 || first.TotalSpots != 450 || first.Other != "W" {
+		t.Errorf("got %+v", first)
+	}
+	if mts, _ := first.GetMeetingTimes(); len(mts) != 2 || mts[1].Building != "EEB" || mts[1].Days != "TTh" {
+		t.Errorf("got meeting times %+v", mts)
+	}
+	if sects[1].LectureKey != "12345" || sects[1].Status != "closed" {
+		t.Errorf("got %+v", sects[1])
+	}
+	errs, ok := err.(errorsSlice)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("got error %v", err)
+	}
+	if !errors.Is(errs[0], ErrShortLine) || !errors.Is(errs[1], ErrBadField) {
+		t.Errorf("got errors %v", errs)
+	}
+}
+
+func TestDetectLayout(t *testing.T) {
+	line := sectLine(defaultWidths, "", "12345", "A", "Reges,Stuart T", "open", " 400/ 450") + "</td>"
+	layout, err := DetectLayout("<pre><b>" + headerRow(defaultWidths) + "</b>\n" + line)
+	if err != nil || layout != DefaultLayout {
+		t.Errorf("got %v, %v", layout, err)
+	}
+	// indented header row with a wider instructor column
+	widths := append([]int(nil), defaultWidths...)
+	widths[8] = 35
+	widths[9] = 7
+	content := "<pre><b>  " + headerRow(widths) + "</b>\n" +
+		sectLine(widths, "", "12345", "A", "Mcdonald-Smith,Alexandra J", "open", " 400/ 450") + "</td>"
+	layout, err = DetectLayout(content)
+	if err != nil || layout.starts[colSLN] != 7 || layout.starts[colStatus] != 91 || layout.starts[colSpots] != 98 {
+		t.Errorf("got %v, %v", layout, err)
+	}
+	sects, err := ExtractSects(content, "cse142")
+	if err != nil || len(sects) != 1 || sects[0].Instructor != "Mcdonald-Smith,Alexandra J" || sects[0].Status != "open" {
+		t.Errorf("got %+v, %v", sects, err)
+	}
+	if _, err := DetectLayout("no header"); err != ErrNoHeader {
+		t.Errorf("got %v", err)
+	}
+	if _, err := DetectLayout("Instructor SLN Restr"); err != ErrBadLayout {
+		t.Errorf("got %v", err)
+	}
+	if _, err := DetectLayout("<pre>" + headerRow(widths) + "\n" + line); err != ErrLayoutInvalid {
+		t.Errorf("got %v", err)
+	}
+}
+
+func FuzzExtractSects(f *testing.F) {
+	f.Add(sectLine(defaultWidths, "Restr", "12345", "A", "Reges,Stuart T", "open", " 400/ 450") + "</td>")
+	f.Add("       <A HREF=h>1</A></td>")
+	f.Add("Restr SLN Instructor\n<A HREF=h></td>")
+	addPages(f, "timeschd_*.html")
+	f.Fuzz(func(t *testing.T, content string) {
+		ExtractSects(content, "class")
+		layout, _ := DetectLayout(content)
+		for _, class := range ExtractClasses(content, "dept") {
+			ExtractSectsWithLayout(content[class.Start:class.End], class.AbbreviationCode, layout)
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package goschedule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The hours and slot length of an occupancy grid, in minutes after midnight.
+const (
+	GridStart = 8 * 60
+	GridEnd   = 22 * 60
+	GridSlot  = 30
+)
+
+// GridDays are the days shown in an occupancy grid.
+var GridDays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+// dayAbbreviations maps the day abbreviations used in MeetingTime.Days to
+// weekdays. Two letter abbreviations are listed first so they match first.
+var dayAbbreviations = []struct {
+	abbreviation string
+	day          time.Weekday
+}{
+	{"Th", time.Thursday},
+	{"Sa", time.Saturday},
+	{"Su", time.Sunday},
+	{"M", time.Monday},
+	{"T", time.Tuesday},
+	{"W", time.Wednesday},
+	{"F", time.Friday},
+}
+
+// Weekdays returns the days of the week the MeetingTime is held, in the order
+// listed in Days.
+func (m MeetingTime) Weekdays() []time.Weekday {
+	var days []time.Weekday
+	s := strings.TrimSpace(m.Days)
+	for len(s) > 0 {
+		matched := false
+		for _, d := range dayAbbreviations {
+			if strings.HasPrefix(s, d.abbreviation) {
+				days = append(days, d.day)
+				s = s[len(d.abbreviation):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			s = s[1:]
+		}
+	}
+	return days
+}
+
+// Interval parses Time (ex. "1030-1120" or "630-920P") into start and end
+// times in minutes after midnight.
+//
+// Times without a "P" suffix follow the time schedule's convention that
+// hours before 8 are in the afternoon. A "P" suffix puts the end time, and
+// the start time if it fits before the end, in the afternoon.
+func (m MeetingTime) Interval() (start, end int, err error) {
+	t := strings.ToUpper(strings.TrimSpace(m.Time))
+	pm := strings.HasSuffix(t, "P")
+	bounds := strings.Split(strings.TrimSuffix(t, "P"), "-")
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("malformed meeting time: %q", m.Time)
+	}
+	startHour, startMinute, err := parseClock(bounds[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	endHour, endMinute, err := parseClock(bounds[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if pm {
+		if endHour < 12 {
+			endHour += 12
+		}
+		if startHour < 12 && (startHour+12)*60+startMinute < endHour*60+endMinute {
+			startHour += 12
+		}
+	} else {
+		if startHour < 8 {
+			startHour += 12
+		}
+		if endHour < 8 || endHour < startHour {
+			endHour += 12
+		}
+	}
+	start, end = startHour*60+startMinute, endHour*60+endMinute
+	if end <= start || end > 24*60 {
+		return 0, 0, fmt.Errorf("malformed meeting time: %q", m.Time)
+	}
+	return start, end, nil
+}
+
+// parseClock parses a time such as "830" or "1030" into hours and minutes.
+func parseClock(s string) (hour, minute int, err error) {
+	if len(s) < 3 || len(s) > 4 || !isDigits(s) {
+		return 0, 0, fmt.Errorf("malformed time: %q", s)
+	}
+	hour, _ = strconv.Atoi(s[:len(s)-2])
+	minute, _ = strconv.Atoi(s[len(s)-2:])
+	if hour > 12 || minute > 59 {
+		return 0, 0, fmt.Errorf("malformed time: %q", s)
+	}
+	return hour, minute, nil
+}
+
+// FormatMinutes formats minutes after midnight as a 12-hour clock time,
+// ex. "1:30 PM".
+func FormatMinutes(minutes int) string {
+	return time.Date(0, 1, 1, minutes/60, minutes%60, 0, 0, time.UTC).Format("3:04 PM")
+}
+
+// A Meeting is a weekly use of a room by a section.
+type Meeting struct {
+	Day      time.Weekday
+	Start    int // minutes after midnight
+	End      int
+	ClassKey string
+	SLN      string
+	Section  string
+}
+
+// A RoomOccupancy holds every meeting in a room during the week.
+type RoomOccupancy struct {
+	Building string
+	Room     string
+	Meetings []Meeting
+}
+
+// Occupancy aggregates the meeting times of sects by building and room.
+// The result is sorted by building, then room, and each room's meetings by
+// day, then start time. Meeting times without a building, room or parsable
+// time (ex. "to be arranged") are skipped.
+func Occupancy(sects []Sect) []RoomOccupancy {
+	rooms := make(map[[2]string]*RoomOccupancy)
+	for _, sect := range sects {
+		meetingTimes, err := sect.GetMeetingTimes()
+		if err != nil {
+			continue
+		}
+		for _, mt := range meetingTimes {
+			if mt.Building == "" || mt.Room == "" {
+				continue
+			}
+			start, end, err := mt.Interval()
+			if err != nil {
+				continue
+			}
+			key := [2]string{strings.ToUpper(mt.Building), strings.ToUpper(mt.Room)}
+			room, ok := rooms[key]
+			if !ok {
+				room = &RoomOccupancy{Building: key[0], Room: key[1]}
+				rooms[key] = room
+			}
+			for _, day := range mt.Weekdays() {
+				room.Meetings = append(room.Meetings, Meeting{day, start, end, sect.ClassKey, sect.SLN, sect.Section})
+			}
+		}
+	}
+	var occupancy []RoomOccupancy
+	for _, room := range rooms {
+		sort.Slice(room.Meetings, func(i, j int) bool {
+			a, b := room.Meetings[i], room.Meetings[j]
+			if a.Day != b.Day {
+				return a.Day < b.Day
+			}
+			return a.Start < b.Start
+		})
+		occupancy = append(occupancy, *room)
+	}
+	sort.Slice(occupancy, func(i, j int) bool {
+		if occupancy[i].Building != occupancy[j].Building {
+			return occupancy[i].Building < occupancy[j].Building
+		}
+		return occupancy[i].Room < occupancy[j].Room
+	})
+	return occupancy
+}
+
+// A GridRow is one time slot of an occupancy grid, with the meetings in the
+// room during the slot for each day in GridDays.
+type GridRow struct {
+	Start int
+	Cells [][]Meeting
+}
+
+// Grid divides the week into GridSlot minute slots from GridStart to GridEnd
+// and lists the meetings overlapping each slot.
+func (o RoomOccupancy) Grid() []GridRow {
+	var rows []GridRow
+	for start := GridStart; start < GridEnd; start += GridSlot {
+		row := GridRow{Start: start, Cells: make([][]Meeting, len(GridDays))}
+		for i, day := range GridDays {
+			for _, meeting := range o.Meetings {
+				if meeting.Day == day && meeting.Start < start+GridSlot && meeting.End > start {
+					row.Cells[i] = append(row.Cells[i], meeting)
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// A FreeSlot is a time the room has no meetings.
+type FreeSlot struct {
+	Day   time.Weekday
+	Start int
+	End   int
+}
+
+// FreeSlots returns the times between GridStart and GridEnd on each day in
+// GridDays that the room has no meetings, in order of day and time.
+func (o RoomOccupancy) FreeSlots() []FreeSlot {
+	var free []FreeSlot
+	for _, day := range GridDays {
+		cursor := GridStart
+		for _, meeting := range o.Meetings {
+			if meeting.Day != day || meeting.End <= cursor {
+				continue
+			}
+			if meeting.Start > cursor && cursor < GridEnd {
+				free = append(free, FreeSlot{day, cursor, minInt(meeting.Start, GridEnd)})
+			}
+			cursor = meeting.End
+		}
+		if cursor < GridEnd {
+			free = append(free, FreeSlot{day, cursor, GridEnd})
+		}
+	}
+	return free
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
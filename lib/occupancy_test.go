@@ -0,0 +1,61 @@
+package goschedule
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMeetingTimeInterval(t *testing.T) {
+	testSet := []struct {
+		in         string
+		start, end int
+		err        bool
+	}{
+		{`1030-1120`, 630, 680, false},
+		{`1230-120`, 750, 800, false},
+		{`130-320`, 810, 920, false},
+		{`630-920P`, 1110, 1280, false},
+		{`1130-1220P`, 690, 740, false},
+		{`to be arranged`, 0, 0, true},
+		{`99-1200`, 0, 0, true},
+	}
+	for _, test := range testSet {
+		start, end, err := MeetingTime{Time: test.in}.Interval()
+		if start != test.start || end != test.end || (err != nil) != test.err {
+			t.Errorf("case %q: got %d, %d, %v", test.in, start, end, err)
+		}
+	}
+}
+
+func TestMeetingTimeWeekdays(t *testing.T) {
+	days := MeetingTime{Days: "MTWThF"}.Weekdays()
+	expected := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	if !reflect.DeepEqual(days, expected) {
+		t.Errorf("got %v", days)
+	}
+}
+
+func TestOccupancy(t *testing.T) {
+	sects := []Sect{
+		{ClassKey: "cse142", SLN: "1", MeetingTimes: `[{"Days":"MWF","Time":"1030-1120","Building":"KNE","Room":"130"}]`},
+		{ClassKey: "cse143", SLN: "2", MeetingTimes: `[{"Days":"TTh","Time":"130-320","Building":"kne","Room":"130"},{"Days":"*","Time":"to be arranged","Building":"","Room":""}]`},
+		{ClassKey: "math124", SLN: "3", MeetingTimes: `[{"Days":"M","Time":"830-920","Building":"GUG","Room":"220"}]`},
+	}
+	occupancy := Occupancy(sects)
+	if len(occupancy) != 2 || occupancy[0].Building != "GUG" || occupancy[1].Room != "130" {
+		t.Fatalf("got %+v", occupancy)
+	}
+	kane := occupancy[1]
+	if len(kane.Meetings) != 5 {
+		t.Errorf("got %d meetings", len(kane.Meetings))
+	}
+	grid := kane.Grid()
+	if len(grid) != (GridEnd-GridStart)/GridSlot || len(grid[5].Cells[0]) != 1 || len(grid[5].Cells[1]) != 0 {
+		t.Errorf("got grid row %+v", grid[5])
+	}
+	free := kane.FreeSlots()
+	if free[0] != (FreeSlot{time.Monday, GridStart, 630}) || free[1] != (FreeSlot{time.Monday, 680, GridEnd}) {
+		t.Errorf("got free slots %+v", free)
+	}
+}
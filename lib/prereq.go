@@ -0,0 +1,160 @@
+package goschedule
+
+import (
+	"strings"
+)
+
+// Operators that combine the arguments of a Prereq.
+const (
+	PrereqAnd = "and"
+	PrereqOr  = "or"
+)
+
+// A Prereq is a prerequisite expression parsed from a course catalog entry.
+//
+// A leaf has no Op and names at most one class by its abbreviation code in
+// Course. Text holds the phrase the leaf was parsed from, such as
+// "minimum grade of 2.0 in CSE 143" or "permission of instructor".
+// Other nodes combine their Args with Op (PrereqAnd or PrereqOr).
+type Prereq struct {
+	Op     string    `json:",omitempty"`
+	Args   []*Prereq `json:",omitempty"`
+	Course string    `json:",omitempty"`
+	Text   string    `json:",omitempty"`
+}
+
+// ParsePrereq parses the text following "Prerequisite:" in a catalog entry.
+//
+// Clauses separated by semicolons are all required. Within a clause, "or"
+// (optionally introduced by "either") makes a list of alternatives, and
+// otherwise commas and "and" make a list of requirements. A class code
+// without a department, as in "MATH 124 or 125", uses the department of the
+// class before it. Returns nil if s is blank.
+func ParsePrereq(s string) *Prereq {
+	var clauses []*Prereq
+	for _, clause := range strings.Split(s, ";") {
+		if p := parsePrereqClause(clause); p != nil {
+			clauses = append(clauses, p)
+		}
+	}
+	return newPrereqNode(PrereqAnd, clauses, "")
+}
+
+func parsePrereqClause(clause string) *Prereq {
+	clause = strings.TrimSpace(clause)
+	if clause == "" {
+		return nil
+	}
+	var dept string
+	if !prereqOrWordRe.MatchString(clause) {
+		return parsePrereqTerms(prereqAndListRe.Split(clause, -1), &dept)
+	}
+	clause = strings.TrimSpace(prereqEitherRe.ReplaceAllString(clause, ""))
+	var args []*Prereq
+	for _, term := range prereqOrListRe.Split(clause, -1) {
+		if p := parsePrereqTerms(prereqAndWordRe.Split(term, -1), &dept); p != nil {
+			args = append(args, p)
+		}
+	}
+	return newPrereqNode(PrereqOr, args, "")
+}
+
+// parsePrereqTerms makes a node requiring all terms. dept is the department
+// of the last class named so far in the clause.
+func parsePrereqTerms(terms []string, dept *string) *Prereq {
+	var args []*Prereq
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		refs := scanCourseRefs(term, dept)
+		switch len(refs) {
+		case 0:
+			args = append(args, &Prereq{Text: term})
+		case 1:
+			args = append(args, &Prereq{Course: refs[0], Text: term})
+		default:
+			var leaves []*Prereq
+			for _, ref := range refs {
+				leaves = append(leaves, &Prereq{Course: ref})
+			}
+			args = append(args, newPrereqNode(PrereqAnd, leaves, term))
+		}
+	}
+	return newPrereqNode(PrereqAnd, args, "")
+}
+
+// newPrereqNode combines args with op, or returns the only arg if there is
+// just one. Returns nil if args is empty.
+func newPrereqNode(op string, args []*Prereq, text string) *Prereq {
+	switch len(args) {
+	case 0:
+		return nil
+	case 1:
+		return args[0]
+	}
+	return &Prereq{Op: op, Args: args, Text: text}
+}
+
+// Courses returns the abbreviation codes of every class named in p, without
+// duplicates, in the order they appear.
+func (p *Prereq) Courses() []string {
+	var courses []string
+	seen := make(map[string]bool)
+	var walk func(*Prereq)
+	walk = func(p *Prereq) {
+		if p == nil {
+			return
+		}
+		if p.Course != "" && !seen[p.Course] {
+			seen[p.Course] = true
+			courses = append(courses, p.Course)
+		}
+		for _, arg := range p.Args {
+			walk(arg)
+		}
+	}
+	walk(p)
+	return courses
+}
+
+// String returns p as a readable expression, such as
+// "cse143 and (math124 or math134)".
+func (p *Prereq) String() string {
+	if p == nil {
+		return ""
+	}
+	if p.Op == "" {
+		if p.Course != "" {
+			return p.Course
+		}
+		return p.Text
+	}
+	var args []string
+	for _, arg := range p.Args {
+		if arg.Op != "" {
+			args = append(args, "("+arg.String()+")")
+		} else {
+			args = append(args, arg.String())
+		}
+	}
+	return strings.Join(args, " "+p.Op+" ")
+}
+
+// scanCourseRefs returns the abbreviation codes of all classes named in s.
+// A bare class code uses *dept, which is updated as departments are named.
+// Bare codes are skipped while *dept is empty.
+func scanCourseRefs(s string, dept *string) []string {
+	var refs []string
+	for _, m := range courseRefRe.FindAllStringSubmatch(s, -1) {
+		if m[1] != "" {
+			*dept = strings.ToLower(strings.Replace(m[1], " ", "", -1))
+		}
+		if *dept == "" {
+			continue
+		}
+		refs = append(refs, *dept+m[2])
+	}
+	return refs
+}
@@ -6,20 +6,22 @@ import (
 
 // These regular expressions are used by the extract methods.
 var (
-	anchorRe               *regexp.Regexp = regexp.MustCompile(`(?is)<a.+?</a>`)
 	parenthesesRe          *regexp.Regexp = regexp.MustCompile(`(?is)\(.*?\)`)
-	collegeLinkRe          *regexp.Regexp = regexp.MustCompile(`<a href="#.+?\|`)
-	collegeChunkRe         *regexp.Regexp = regexp.MustCompile(`(?i)<a name.+?</a>\n<h2>.+?</h2>`)
-	classChunkRe           *regexp.Regexp = regexp.MustCompile(`(?is)<table bgcolor="#99ccff".*?</table>`)
-	classNameRe            *regexp.Regexp = regexp.MustCompile(`(?i)name=.*?>`)
 	classAbbreviationRe    *regexp.Regexp = regexp.MustCompile(`[a-z]+`)
 	classCodeRe            *regexp.Regexp = regexp.MustCompile(`\d+`)
-	classTitleRe           *regexp.Regexp = regexp.MustCompile(`(?i)<a href.*?>.+?</a>`)
-	tagRe                  *regexp.Regexp = regexp.MustCompile(`(?i)<.+?>`)
-	sectChunkRe            *regexp.Regexp = regexp.MustCompile(`(?s).{7}<A HREF=h.+?</td>`)
 	meetingTimeRe          *regexp.Regexp = regexp.MustCompile(`(?i)\w{1,5}\s*\d{3,4}-\d{3,4}`)
 	spotsRe                *regexp.Regexp = regexp.MustCompile(`\d+`)
-	classDescriptionLinkRe *regexp.Regexp = regexp.MustCompile(`<a href="?(\w+[.]html)"?>`)
-	classDescriptionRe     *regexp.Regexp = regexp.MustCompile(`(?is)<p><b><a name="?(.+?)"?>.*?</a>.*?</b>(.*?)\n\n`)
+	classDescriptionLinkRe *regexp.Regexp = regexp.MustCompile(`^\w+[.]html$`)
 	blankLineRe            *regexp.Regexp = regexp.MustCompile(`^\s*$`)
+	catalogCreditsRe       *regexp.Regexp = regexp.MustCompile(`\(([^()]*\d[^()]*|\*[^()]*)\)\s*([^()]*)$`)
+	catalogPrereqRe        *regexp.Regexp = regexp.MustCompile(`(?is)prerequisites?:\s*(.+?)(?:\.\s|\.$|$)`)
+	catalogOfferedRe       *regexp.Regexp = regexp.MustCompile(`(?is)offered:\s*(.+?)(?:\.\s|\.$|$)`)
+	catalogQuartersRe      *regexp.Regexp = regexp.MustCompile(`^(?:A|W|Sp|S)+$`)
+	prereqOrWordRe         *regexp.Regexp = regexp.MustCompile(`(?i)\b(?:or|either)\b`)
+	prereqEitherRe         *regexp.Regexp = regexp.MustCompile(`(?i)^either\s+`)
+	prereqOrListRe         *regexp.Regexp = regexp.MustCompile(`(?i),?\s+or\s+|,\s*`)
+	prereqAndListRe        *regexp.Regexp = regexp.MustCompile(`(?i),?\s+and\s+|,\s*`)
+	prereqAndWordRe        *regexp.Regexp = regexp.MustCompile(`(?i)\s+and\s+`)
+	wordRe                 *regexp.Regexp = regexp.MustCompile(`\S+`)
+	courseRefRe            *regexp.Regexp = regexp.MustCompile(`(?:((?:[A-Z][A-Z&]*\s)*[A-Z][A-Z&]*)\s*)?\b(\d{3})(?:\D|$)`)
 )